@@ -39,7 +39,7 @@ func NewInteractiveAgent(
 	name string,
 	systemPrompt string,
 	registry *tools.Registry,
-	client *llm.Client,
+	client llm.Provider,
 	uiCallbacks UICallbacks,
 	config llm.ModelConfig,
 	permissionManager *common.PermissionManager,
@@ -129,11 +129,15 @@ func (ia *InteractiveAgent) handleToolCalls(ctx context.Context, toolName string
 	var alternate = ""
 
 	detail := tool.Explain(args)
+	command, paths := tool.PermissionSubjects(args)
 	request := common.PermissionRequest{
 		ToolName:  toolName,
 		Arguments: args,
 		Title:     detail.Title,
 		Context:   detail.Context,
+		Command:   command,
+		Paths:     paths,
+		ReadOnly:  !tool.Modifies(),
 	}
 
 	// Request permission
@@ -143,7 +147,7 @@ func (ia *InteractiveAgent) handleToolCalls(ctx context.Context, toolName string
 
 	if execute {
 		// Execute the tool
-		result, err := tool.Run(args)
+		result, err := tool.Run(ctx, args)
 
 		// Display the result if callback is provided
 		if ia.uiCallbacks.PrintToolCall != nil {
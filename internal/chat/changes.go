@@ -0,0 +1,161 @@
+package chat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/recrsn/coder/internal/diff"
+)
+
+// changeTracker remembers the files tools changed, as they were before the
+// session and before the current turn changed them
+type changeTracker struct {
+	mu      sync.Mutex
+	session map[string]fileSnapshot
+	turn    map[string]fileSnapshot
+}
+
+// fileChange is the difference between a file before and after changes
+type fileChange struct {
+	before fileSnapshot
+	after  fileSnapshot
+}
+
+// record keeps files as the original state of the files not yet changed
+func (t *changeTracker) record(files []fileSnapshot) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.session == nil {
+		t.session = make(map[string]fileSnapshot)
+	}
+	if t.turn == nil {
+		t.turn = make(map[string]fileSnapshot)
+	}
+	for _, file := range files {
+		if _, ok := t.session[file.path]; !ok {
+			t.session[file.path] = file
+		}
+		if _, ok := t.turn[file.path]; !ok {
+			t.turn[file.path] = file
+		}
+	}
+}
+
+// startTurn forgets the changes of the previous turn
+func (t *changeTracker) startTurn() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.turn = nil
+}
+
+// sessionChanges returns the files that differ from before the session
+func (t *changeTracker) sessionChanges() ([]fileChange, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return changesSince(t.session)
+}
+
+// turnChanges returns the files that differ from before the current turn
+func (t *changeTracker) turnChanges() ([]fileChange, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return changesSince(t.turn)
+}
+
+// changesSince compares the files of originals to their current state, by path
+func changesSince(originals map[string]fileSnapshot) ([]fileChange, error) {
+	paths := make([]string, 0, len(originals))
+	for path := range originals {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var changes []fileChange
+	for _, path := range paths {
+		before := originals[path]
+		after := fileSnapshot{path: path}
+		content, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			after.exists = true
+			after.content = content
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, err
+		}
+		if before.exists != after.exists || !bytes.Equal(before.content, after.content) {
+			changes = append(changes, fileChange{before: before, after: after})
+		}
+	}
+	return changes, nil
+}
+
+// stat returns how many lines the change added and removed
+func (c fileChange) stat() (added, removed int) {
+	return diff.Stat(string(c.before.content), string(c.after.content))
+}
+
+// unified returns the change as a unified diff, naming the file name
+func (c fileChange) unified(name string) string {
+	oldName, newName := "a/"+name, "b/"+name
+	if !c.before.exists {
+		oldName = "/dev/null"
+	}
+	if !c.after.exists {
+		newName = "/dev/null"
+	}
+	return diff.Unified(oldName, newName, string(c.before.content), string(c.after.content))
+}
+
+// summarizeChanges describes changes as "N files changed, +X/−Y"
+func summarizeChanges(changes []fileChange) string {
+	added, removed := 0, 0
+	for _, change := range changes {
+		a, r := change.stat()
+		added += a
+		removed += r
+	}
+	files := "files"
+	if len(changes) == 1 {
+		files = "file"
+	}
+	return fmt.Sprintf("%d %s changed, +%d/−%d", len(changes), files, added, removed)
+}
+
+// diffCommand handles /diff, which shows the changes tools made to files in
+// this session
+func (s *Session) diffCommand() error {
+	changes, err := s.changes.sessionChanges()
+	if err != nil {
+		return fmt.Errorf("comparing the changed files: %w", err)
+	}
+	if len(changes) == 0 {
+		s.ui.PrintInfo("No files changed in this session")
+		return nil
+	}
+
+	var text strings.Builder
+	for _, change := range changes {
+		// Paths outside the working directory lose their leading slash, like
+		// in git diffs
+		text.WriteString(change.unified(strings.TrimPrefix(filepath.ToSlash(s.relativePath(change.after.path)), "/")))
+	}
+	s.ui.PrintDiff(strings.TrimRight(text.String(), "\n"))
+	s.ui.PrintInfo(summarizeChanges(changes))
+	return nil
+}
+
+// printTurnSummary shows how many files the last turn changed, if any
+func (s *Session) printTurnSummary() {
+	changes, err := s.changes.turnChanges()
+	if err != nil || len(changes) == 0 {
+		return
+	}
+	s.ui.PrintInfo(summarizeChanges(changes) + ", /diff shows the changes of the session")
+}
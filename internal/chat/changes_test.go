@@ -0,0 +1,64 @@
+package chat
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/recrsn/coder/internal/common"
+	"github.com/recrsn/coder/internal/config"
+	"github.com/recrsn/coder/internal/tools"
+	"github.com/recrsn/coder/internal/ui"
+)
+
+func TestSessionChanges(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.txt")
+	if err := os.WriteFile(existing, []byte("a\nb\nc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := tools.NewRegistry()
+	registry.Register("write", tools.NewWriteTool(nil))
+	permissionManager := common.NewPermissionManager(config.PermissionConfig{}, common.NewNonInteractivePermissionHandler(nil))
+	permissionManager.SetMode(common.ModeBypass)
+	var output strings.Builder
+	session := &Session{
+		ui:                ui.NewHeadlessUI(nil, nil, &output),
+		registry:          registry,
+		permissionManager: permissionManager,
+		workingDir:        dir,
+	}
+
+	write := func(path, content string) {
+		t.Helper()
+		if _, err := session.HandleToolCalls(context.Background(), "write", map[string]any{"path": path, "content": content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(existing, "a\nB\nc\n")
+	session.changes.startTurn()
+	write(existing, "a\nB\nc\nd\n")
+	write(filepath.Join(dir, "new.txt"), "new\n")
+
+	changes, err := session.changes.turnChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary := summarizeChanges(changes); summary != "2 files changed, +2/−0" {
+		t.Errorf("Expected the turn to change 2 files, got %q", summary)
+	}
+
+	if err := session.diffCommand(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "--- a/existing.txt\n+++ b/existing.txt\n@@ -1,3 +1,4 @@\n a\n-b\n+B\n c\n+d\n" +
+		"--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+new\n" +
+		"2 files changed, +3/−1\n"
+	if output.String() != expected {
+		t.Errorf("Expected the diff of the session:\n%s\ngot:\n%s", expected, output.String())
+	}
+}
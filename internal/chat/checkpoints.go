@@ -0,0 +1,387 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/recrsn/coder/internal/tools"
+)
+
+// maxCheckpoints is how many checkpoints a session keeps, older ones are
+// deleted
+const maxCheckpoints = 100
+
+// checkpoint is a restore point: the files a tool call changed, as they were
+// before the call
+type checkpoint struct {
+	turn  int
+	title string
+	time  time.Time
+	files []fileSnapshot
+	// saved is the file the checkpoint is saved to, empty if it is only kept
+	// in memory. The content of its files is only read from it to restore
+	// them.
+	saved string
+}
+
+// fileSnapshot is the content of a file, or its absence
+type fileSnapshot struct {
+	path    string
+	exists  bool
+	content []byte
+	mode    fs.FileMode
+}
+
+// checkpointRecord is a checkpoint as it is saved
+type checkpointRecord struct {
+	Turn  int            `json:"turn"`
+	Title string         `json:"title"`
+	Time  time.Time      `json:"time"`
+	Files []snapshotFile `json:"files"`
+}
+
+// snapshotFile is a fileSnapshot as it is saved
+type snapshotFile struct {
+	Path    string      `json:"path"`
+	Exists  bool        `json:"exists"`
+	Content []byte      `json:"content,omitempty"`
+	Mode    fs.FileMode `json:"mode,omitempty"`
+}
+
+// checkpointStore keeps the last maxCheckpoints restore points of a session.
+// With a directory they are saved there next to the session's transcript, so
+// they survive resuming it, and only kept in memory otherwise.
+type checkpointStore struct {
+	mu          sync.Mutex
+	dir         string
+	checkpoints []checkpoint
+	// next numbers the files of the checkpoints in dir
+	next int
+}
+
+// snapshot returns a checkpoint of paths as they are now
+func snapshot(turn int, title string, paths []string) (checkpoint, error) {
+	cp := checkpoint{turn: turn, title: title, time: time.Now()}
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return checkpoint{}, err
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		file := fileSnapshot{path: path}
+		info, err := os.Stat(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// Undo deletes the file the call creates
+		case err != nil:
+			return checkpoint{}, err
+		case info.IsDir():
+			continue
+		default:
+			if file.content, err = os.ReadFile(path); err != nil {
+				return checkpoint{}, err
+			}
+			file.exists = true
+			file.mode = info.Mode().Perm()
+		}
+		cp.files = append(cp.files, file)
+	}
+	return cp, nil
+}
+
+// open switches the store to the checkpoints saved in dir, which is created
+// with the first one. Checkpoints that can't be read are skipped.
+func (s *checkpointStore) open(dir string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dir = dir
+	s.checkpoints = nil
+	s.next = 0
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	// The names are zero-padded numbers, so they sort in order
+	sort.Strings(paths)
+	for _, path := range paths {
+		if n, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(path), ".json")); err == nil {
+			s.next = max(s.next, n)
+		}
+		cp, err := readCheckpoint(path)
+		if err != nil {
+			continue
+		}
+		s.checkpoints = append(s.checkpoints, cp.withoutContent())
+	}
+	return nil
+}
+
+// add keeps cp, saving it if the store has a directory, and deletes the
+// oldest checkpoints beyond maxCheckpoints
+func (s *checkpointStore) add(cp checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir != "" {
+		s.next++
+		path := filepath.Join(s.dir, fmt.Sprintf("%06d.json", s.next))
+		if err := writeCheckpoint(path, cp); err != nil {
+			return err
+		}
+		cp.saved = path
+		cp = cp.withoutContent()
+	}
+	s.checkpoints = append(s.checkpoints, cp)
+
+	if excess := len(s.checkpoints) - maxCheckpoints; excess > 0 {
+		for _, old := range s.checkpoints[:excess] {
+			if old.saved != "" {
+				os.Remove(old.saved)
+			}
+		}
+		s.checkpoints = slices.Delete(s.checkpoints, 0, excess)
+	}
+	return nil
+}
+
+func (s *checkpointStore) list() []checkpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]checkpoint(nil), s.checkpoints...)
+}
+
+// last returns the last checkpoint, or with wholeTurn all the checkpoints of
+// the last turn that has any, latest first, with the content of their files.
+// They are kept until they are dropped.
+func (s *checkpointStore) last(wholeTurn bool) ([]checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.checkpoints)
+	if n == 0 {
+		return nil, nil
+	}
+	start := n - 1
+	if wholeTurn {
+		for start > 0 && s.checkpoints[start-1].turn == s.checkpoints[n-1].turn {
+			start--
+		}
+	}
+
+	last := make([]checkpoint, 0, n-start)
+	for i := n - 1; i >= start; i-- {
+		cp := s.checkpoints[i]
+		if cp.saved != "" {
+			saved, err := readCheckpoint(cp.saved)
+			if err != nil {
+				return nil, fmt.Errorf("reading checkpoint: %w", err)
+			}
+			cp.files = saved.files
+		}
+		last = append(last, cp)
+	}
+	return last, nil
+}
+
+// drop deletes the last n checkpoints
+func (s *checkpointStore) drop(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := max(len(s.checkpoints)-n, 0)
+	for _, cp := range s.checkpoints[start:] {
+		if cp.saved != "" {
+			os.Remove(cp.saved)
+		}
+	}
+	s.checkpoints = s.checkpoints[:start]
+}
+
+// lastTurn returns the turn of the last checkpoint, 0 if there is none
+func (s *checkpointStore) lastTurn() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.checkpoints) == 0 {
+		return 0
+	}
+	return s.checkpoints[len(s.checkpoints)-1].turn
+}
+
+// withoutContent returns cp without the content of its files, which is
+// read from its saved file when needed
+func (cp checkpoint) withoutContent() checkpoint {
+	files := make([]fileSnapshot, len(cp.files))
+	for i, file := range cp.files {
+		file.content = nil
+		files[i] = file
+	}
+	cp.files = files
+	return cp
+}
+
+func writeCheckpoint(path string, cp checkpoint) error {
+	record := checkpointRecord{Turn: cp.turn, Title: cp.title, Time: cp.time}
+	for _, file := range cp.files {
+		record.Files = append(record.Files, snapshotFile{
+			Path:    file.path,
+			Exists:  file.exists,
+			Content: file.content,
+			Mode:    file.mode,
+		})
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("creating checkpoints directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("saving checkpoint: %w", err)
+	}
+	return nil
+}
+
+func readCheckpoint(path string) (checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return checkpoint{}, err
+	}
+	var record checkpointRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return checkpoint{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	cp := checkpoint{turn: record.Turn, title: record.Title, time: record.Time, saved: path}
+	for _, file := range record.Files {
+		cp.files = append(cp.files, fileSnapshot{
+			path:    file.Path,
+			exists:  file.Exists,
+			content: file.Content,
+			mode:    file.Mode,
+		})
+	}
+	return cp, nil
+}
+
+// restore puts the files of cp back as they were, all of them or none, the
+// way the file tools write files, and returns their paths
+func (cp checkpoint) restore() ([]string, error) {
+	var changes []tools.FileChange
+	var paths []string
+	for _, file := range cp.files {
+		if file.exists {
+			changes = append(changes, tools.FileChange{Path: file.path, Content: file.content, Mode: file.mode})
+		} else if _, err := os.Lstat(file.path); err == nil {
+			changes = append(changes, tools.FileChange{Path: file.path, Delete: true})
+		}
+		paths = append(paths, file.path)
+	}
+	if err := tools.WriteFiles(changes); err != nil {
+		return nil, fmt.Errorf("restoring %s: %w", cp.title, err)
+	}
+	return paths, nil
+}
+
+// undoCommand handles /undo, which rolls back the last tool call that changed
+// files, or with "turn" every such call of the last turn. A checkpoint is
+// only dropped once its files are restored.
+func (s *Session) undoCommand(arg string) error {
+	if arg != "" && arg != "turn" {
+		return fmt.Errorf("usage: /undo [turn]")
+	}
+
+	checkpoints, err := s.checkpoints.last(arg == "turn")
+	if err != nil {
+		return err
+	}
+	if len(checkpoints) == 0 {
+		s.ui.PrintInfo("Nothing to undo")
+		return nil
+	}
+
+	restored := make(map[string]bool)
+	undone := 0
+	for _, cp := range checkpoints {
+		var paths []string
+		if paths, err = cp.restore(); err != nil {
+			break
+		}
+		for _, path := range paths {
+			restored[path] = true
+		}
+		undone++
+		s.ui.PrintSuccess("Undid " + cp.title)
+	}
+	s.checkpoints.drop(undone)
+
+	paths := make([]string, 0, len(restored))
+	for path := range restored {
+		paths = append(paths, s.relativePath(path))
+	}
+	sort.Strings(paths)
+	if len(paths) > 0 {
+		s.ui.PrintInfo("Restored " + strings.Join(paths, ", "))
+		s.undoneFiles = append(s.undoneFiles, paths...)
+	}
+	return err
+}
+
+// checkpointsCommand handles /checkpoints, which lists the restore points
+func (s *Session) checkpointsCommand() error {
+	checkpoints := s.checkpoints.list()
+	if len(checkpoints) == 0 {
+		s.ui.PrintInfo("No checkpoints")
+		return nil
+	}
+
+	var list strings.Builder
+	list.WriteString("Checkpoints, /undo restores the last one and /undo turn those of the last turn:\n")
+	for i, cp := range checkpoints {
+		paths := make([]string, len(cp.files))
+		for j, file := range cp.files {
+			paths[j] = s.relativePath(file.path)
+		}
+		fmt.Fprintf(&list, "%d. turn %d, %s %s: %s\n", i+1, cp.turn, cp.time.Format("15:04:05"), cp.title,
+			strings.Join(paths, ", "))
+	}
+	s.ui.PrintInfo(strings.TrimSpace(list.String()))
+	return nil
+}
+
+// withUndoNote tells the model which files the user restored since its last
+// message, as its knowledge of them is out of date
+func (s *Session) withUndoNote(message string) string {
+	if len(s.undoneFiles) == 0 {
+		return message
+	}
+	note := "\n\n<system-reminder>The user undid your changes to " + strings.Join(s.undoneFiles, ", ") +
+		". Read them again before changing them.</system-reminder>"
+	s.undoneFiles = nil
+	return message + note
+}
+
+// relativePath returns path relative to the working directory if it is inside it
+func (s *Session) relativePath(path string) string {
+	if rel, err := filepath.Rel(s.workingDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
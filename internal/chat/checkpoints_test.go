@@ -0,0 +1,173 @@
+package chat
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/recrsn/coder/internal/common"
+	"github.com/recrsn/coder/internal/config"
+	"github.com/recrsn/coder/internal/tools"
+	"github.com/recrsn/coder/internal/ui"
+)
+
+func TestSessionUndo(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.txt")
+	created := filepath.Join(dir, "created.txt")
+	if err := os.WriteFile(existing, []byte("original\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := tools.NewRegistry()
+	registry.Register("write", tools.NewWriteTool(nil))
+	permissionManager := common.NewPermissionManager(config.PermissionConfig{}, common.NewNonInteractivePermissionHandler(nil))
+	permissionManager.SetMode(common.ModeBypass)
+	session := &Session{
+		ui:                ui.NewHeadlessUI(nil, nil, io.Discard),
+		registry:          registry,
+		permissionManager: permissionManager,
+		workingDir:        dir,
+	}
+
+	write := func(turn int, path, content string) {
+		t.Helper()
+		session.turn = turn
+		result, err := session.HandleToolCalls(context.Background(), "write", map[string]any{"path": path, "content": content})
+		if err != nil || !strings.HasPrefix(result, "File written") {
+			t.Fatalf("Expected the write to succeed, got %q, %v", result, err)
+		}
+	}
+	expectFiles := func(existingContent string, createdExists bool) {
+		t.Helper()
+		if content, _ := os.ReadFile(existing); string(content) != existingContent {
+			t.Errorf("Expected %q in existing.txt, got %q", existingContent, content)
+		}
+		if _, err := os.Stat(created); (err == nil) != createdExists {
+			t.Errorf("Expected created.txt to exist: %v, got %v", createdExists, err)
+		}
+	}
+
+	write(1, existing, "first\n")
+	write(2, existing, "second\n")
+	write(2, created, "new\n")
+	if checkpoints := session.checkpoints.list(); len(checkpoints) != 3 {
+		t.Fatalf("Expected 3 checkpoints, got %d", len(checkpoints))
+	}
+
+	if err := session.undoCommand(""); err != nil {
+		t.Fatal(err)
+	}
+	expectFiles("second\n", false)
+
+	write(2, created, "new\n")
+	if err := session.undoCommand("turn"); err != nil {
+		t.Fatal(err)
+	}
+	expectFiles("first\n", false)
+	if note := session.withUndoNote("next"); !strings.Contains(note, "created.txt, existing.txt") {
+		t.Errorf("Expected a note about the restored files, got %q", note)
+	}
+
+	if err := session.undoCommand("turn"); err != nil {
+		t.Fatal(err)
+	}
+	expectFiles("original\n", false)
+	if len(session.checkpoints.list()) != 0 {
+		t.Error("Expected no checkpoints left")
+	}
+}
+
+func TestCheckpointStoreSavesCheckpoints(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "session.checkpoints")
+	path := filepath.Join(t.TempDir(), "main.go")
+
+	var store checkpointStore
+	if err := store.open(dir); err != nil {
+		t.Fatal(err)
+	}
+	for i := range maxCheckpoints + 1 {
+		cp := checkpoint{turn: i + 1, title: "Write(main.go)", files: []fileSnapshot{
+			{path: path, exists: true, content: []byte(strconv.Itoa(i)), mode: 0644},
+		}}
+		if err := store.add(cp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if saved, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(saved) != maxCheckpoints {
+		t.Errorf("Expected the oldest checkpoint to be deleted, %d are saved", len(saved))
+	}
+
+	// Resuming the session loads them
+	var resumed checkpointStore
+	if err := resumed.open(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.list()) != maxCheckpoints || resumed.lastTurn() != maxCheckpoints+1 {
+		t.Fatalf("Expected %d checkpoints up to turn %d, got %d up to turn %d",
+			maxCheckpoints, maxCheckpoints+1, len(resumed.list()), resumed.lastTurn())
+	}
+	last, err := resumed.last(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(last) != 1 || string(last[0].files[0].content) != strconv.Itoa(maxCheckpoints) {
+		t.Errorf("Expected the last checkpoint with its content, got %+v", last)
+	}
+	resumed.drop(1)
+	if _, err := os.Stat(last[0].saved); !os.IsNotExist(err) {
+		t.Errorf("Expected the dropped checkpoint to be deleted, got %v", err)
+	}
+}
+
+func TestSessionUndoKeepsCheckpointOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "script.sh")
+	if err := os.WriteFile(path, []byte("original\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := snapshot(1, "Write(script.sh)", []string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &Session{ui: ui.NewHeadlessUI(nil, nil, io.Discard), workingDir: dir}
+	if err := session.checkpoints.add(cp); err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory in place of the file can't be restored
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.undoCommand(""); err == nil {
+		t.Fatal("Expected the undo to fail")
+	}
+	if len(session.checkpoints.list()) != 1 {
+		t.Fatal("Expected the checkpoint to be kept")
+	}
+
+	// The mode is restored with the content
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.undoCommand(""); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if content, _ := os.ReadFile(path); err != nil || string(content) != "original\n" || info.Mode().Perm() != 0755 {
+		t.Errorf("Expected the original content and mode, got %q, %v", content, err)
+	}
+	if len(session.checkpoints.list()) != 0 {
+		t.Error("Expected the checkpoint to be dropped")
+	}
+}
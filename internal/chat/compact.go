@@ -0,0 +1,83 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/recrsn/coder/internal/llm"
+)
+
+// summaryTimeout bounds the summary requests of /summarize and /compact.
+// Summarizing a long conversation takes several requests.
+const summaryTimeout = 2 * time.Minute
+
+// commandContext returns the context of a command's model requests, which an
+// interrupt cancels and which times out after summaryTimeout
+func (s *Session) commandContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+	s.cancelFunc = cancel
+	return ctx, func() {
+		s.cancelFunc = nil
+		cancel()
+	}
+}
+
+// compact summarizes the conversation except for the system prompt and the
+// last keepTurns turns, and replaces the summarized messages with the
+// summary. It returns the summary and the number of messages replaced, which
+// is 0 if there is nothing to compact.
+func (s *Session) compact(ctx context.Context, keepTurns int) (string, int, error) {
+	start := llm.TurnStart(s.agent.Messages, keepTurns)
+	if start <= 1 {
+		return "", 0, nil
+	}
+
+	messages := s.agent.Messages[1:start]
+	summary, err := s.SummarizeMessages(ctx, messages)
+	if err != nil {
+		return "", 0, err
+	}
+
+	s.agent.CompactMessages(start, llm.Message{
+		Role:    "user",
+		Content: continuationMessage(summary),
+	})
+	if s.transcript != nil {
+		if err := s.transcript.Reset(s.agent.Messages[1:]); err != nil {
+			s.ui.PrintError(fmt.Sprintf("Failed to save session: %v", err))
+		}
+	}
+	return summary, len(messages), nil
+}
+
+// compactIfNeeded compacts the conversation once it fills the configured
+// share of the model's context window. It runs before every request of the
+// session's agent.
+func (s *Session) compactIfNeeded(ctx context.Context) error {
+	window := s.config.Context.WindowFor(selectModel("chat", s.config).Model)
+	tokens := s.agent.ContextTokens()
+	if window <= 0 || float64(tokens) < float64(window)*s.config.Context.CompactThreshold {
+		return nil
+	}
+	if llm.TurnStart(s.agent.Messages, s.config.Context.KeepTurns) <= 1 {
+		// Only the turns that are kept are left
+		return nil
+	}
+
+	s.ui.PrintInfo(fmt.Sprintf("Conversation uses %d%% of the context window, compacting", tokens*100/window))
+
+	_, compacted, err := s.compact(ctx, s.config.Context.KeepTurns)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("operation interrupted")
+		}
+		// The request may still fit, so carry on with the full conversation
+		s.ui.PrintError(fmt.Sprintf("Failed to compact conversation: %v", err))
+		return nil
+	}
+	if compacted > 0 {
+		s.ui.PrintSuccess(fmt.Sprintf("Compacted %d messages into a summary", compacted))
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/recrsn/coder/internal/llm"
+)
+
+// Output formats of a headless run
+const (
+	OutputText       = "text"
+	OutputJSON       = "json"
+	OutputStreamJSON = "stream-json"
+)
+
+// RunPrompt runs a single prompt to completion without user interaction and
+// returns the final assistant message
+func (s *Session) RunPrompt(ctx context.Context, prompt string) (llm.Message, error) {
+	s.agent.AddMessage("user", prompt)
+	message, err := s.agent.Run(ctx)
+	s.saveTranscript()
+	return message, err
+}
+
+// HeadlessOutput writes the result of a headless run in one of the output
+// formats. Text prints the tool calls as they run, then the final message
+// and the usage. JSON prints a single object once the run has finished, and
+// stream-json prints one object per line for every message and tool call
+// followed by the same result object.
+type HeadlessOutput struct {
+	format    string
+	out       io.Writer
+	mu        sync.Mutex
+	toolCalls []headlessToolCall
+}
+
+type headlessToolCall struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+	Result    string         `json:"result,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+type headlessUsage struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+type headlessResult struct {
+	Type      string             `json:"type,omitempty"`
+	Result    string             `json:"result"`
+	IsError   bool               `json:"is_error"`
+	Error     string             `json:"error,omitempty"`
+	ToolCalls []headlessToolCall `json:"tool_calls"`
+	Usage     headlessUsage      `json:"usage"`
+}
+
+// NewHeadlessOutput creates the output of a headless run written to out
+func NewHeadlessOutput(format string, out io.Writer) (*HeadlessOutput, error) {
+	switch format {
+	case OutputText, OutputJSON, OutputStreamJSON:
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected text, json or stream-json", format)
+	}
+	return &HeadlessOutput{format: format, out: out}, nil
+}
+
+// HandleMessage records an assistant message
+func (o *HeadlessOutput) HandleMessage(message string) {
+	if o.format != OutputStreamJSON || message == "" {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.writeJSON(map[string]string{"type": "assistant", "message": message})
+}
+
+// HandleToolCall records a tool call and its result
+func (o *HeadlessOutput) HandleToolCall(toolName string, args map[string]any, result string, err error) {
+	call := headlessToolCall{
+		Name:      toolName,
+		Arguments: args,
+		Result:    truncateMiddle(result, maxToolOutputChars),
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.toolCalls = append(o.toolCalls, call)
+
+	switch o.format {
+	case OutputText:
+		status := "ok"
+		if err != nil {
+			status = "failed: " + err.Error()
+		}
+		_, _ = fmt.Fprintf(o.out, "> %s(%s) %s\n", toolName, condenseToolArguments(args), status)
+	case OutputStreamJSON:
+		o.writeJSON(struct {
+			Type string `json:"type"`
+			headlessToolCall
+		}{"tool_call", call})
+	}
+}
+
+// Finish writes the final message, or the error that ended the run, and the usage
+func (o *HeadlessOutput) Finish(message llm.Message, usage llm.UsageSummary, runErr error) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	result := headlessResult{
+		Result:    message.Content,
+		ToolCalls: o.toolCalls,
+		Usage: headlessUsage{
+			Requests:         usage.Total.Requests,
+			PromptTokens:     usage.Total.PromptTokens,
+			CompletionTokens: usage.Total.CompletionTokens,
+			CostUSD:          usage.Total.Cost,
+		},
+	}
+	if result.ToolCalls == nil {
+		result.ToolCalls = []headlessToolCall{}
+	}
+	if runErr != nil {
+		result.IsError = true
+		result.Error = runErr.Error()
+	}
+
+	switch o.format {
+	case OutputText:
+		if runErr != nil {
+			_, _ = fmt.Fprintf(o.out, "Error: %v\n", runErr)
+		} else {
+			_, _ = fmt.Fprintln(o.out, message.Content)
+		}
+		_, _ = fmt.Fprintf(o.out, "\nUsage: %d requests, %d prompt tokens, %d completion tokens, $%.4f\n",
+			result.Usage.Requests, result.Usage.PromptTokens, result.Usage.CompletionTokens, result.Usage.CostUSD)
+		return nil
+	case OutputStreamJSON:
+		result.Type = "result"
+	}
+	return o.writeJSON(result)
+}
+
+func (o *HeadlessOutput) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding output: %w", err)
+	}
+	_, err = fmt.Fprintln(o.out, string(data))
+	return err
+}
+
+// condenseToolArguments renders tool arguments as key=value pairs
+func condenseToolArguments(args map[string]any) string {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return ""
+	}
+	return truncateMiddle(condenseArguments(string(data)), maxArgumentChars)
+}
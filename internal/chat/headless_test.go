@@ -0,0 +1,70 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/recrsn/coder/internal/llm"
+)
+
+func TestHeadlessOutputJSON(t *testing.T) {
+	var out bytes.Buffer
+	output, err := NewHeadlessOutput(OutputJSON, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output.HandleMessage("Let me look")
+	output.HandleToolCall("read", map[string]any{"path": "main.go"}, "package main", nil)
+	output.HandleToolCall("shell", map[string]any{"command": "rm -rf /"}, "", errors.New("permission denied"))
+	usage := llm.UsageSummary{Total: llm.UsageTotal{Requests: 2, PromptTokens: 100, CompletionTokens: 20}}
+	if err := output.Finish(llm.Message{Content: "Done"}, usage, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var result headlessResult
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("expected a single JSON object, got %q: %v", out.String(), err)
+	}
+	if result.Result != "Done" || result.IsError || result.Usage.Requests != 2 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(result.ToolCalls) != 2 || result.ToolCalls[1].Error != "permission denied" {
+		t.Errorf("unexpected tool call log: %+v", result.ToolCalls)
+	}
+}
+
+func TestHeadlessOutputStreamJSON(t *testing.T) {
+	var out bytes.Buffer
+	output, _ := NewHeadlessOutput(OutputStreamJSON, &out)
+
+	output.HandleMessage("Let me look")
+	output.HandleToolCall("read", map[string]any{"path": "main.go"}, "package main", nil)
+	_ = output.Finish(llm.Message{}, llm.UsageSummary{}, errors.New("reached the maximum of 1 turns"))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 events, got %q", out.String())
+	}
+	for i, want := range []string{"assistant", "tool_call", "result"} {
+		var event struct {
+			Type    string `json:"type"`
+			IsError bool   `json:"is_error"`
+		}
+		if err := json.Unmarshal([]byte(lines[i]), &event); err != nil || event.Type != want {
+			t.Errorf("expected event %d to be %s, got %q", i, want, lines[i])
+		}
+		if want == "result" && !event.IsError {
+			t.Errorf("expected the result to be an error: %q", lines[i])
+		}
+	}
+}
+
+func TestNewHeadlessOutputRejectsUnknownFormat(t *testing.T) {
+	if _, err := NewHeadlessOutput("yaml", &bytes.Buffer{}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
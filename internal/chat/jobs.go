@@ -0,0 +1,35 @@
+package chat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jobsCommand handles /jobs, which lists the background jobs, and
+// "/jobs kill <id>", which kills one
+func (s *Session) jobsCommand(arg string) error {
+	if s.shell == nil {
+		s.ui.PrintInfo("No background jobs")
+		return nil
+	}
+	if arg == "" {
+		s.ui.PrintInfo(strings.TrimSpace(s.shell.DescribeJobs()))
+		return nil
+	}
+
+	idArg, ok := strings.CutPrefix(arg, "kill ")
+	if !ok {
+		return fmt.Errorf("usage: /jobs [kill <id>]")
+	}
+	id, err := strconv.Atoi(strings.TrimSpace(idArg))
+	if err != nil {
+		return fmt.Errorf("invalid job id: %s", idArg)
+	}
+	job, err := s.shell.KillJob(id)
+	if err != nil {
+		return err
+	}
+	s.ui.PrintSuccess(fmt.Sprintf("Killed job %d: %s", job.ID, job.Command))
+	return nil
+}
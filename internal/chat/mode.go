@@ -0,0 +1,50 @@
+package chat
+
+import (
+	"fmt"
+
+	"github.com/recrsn/coder/internal/common"
+)
+
+// modeCommand handles /mode. Without an argument it shows the current mode.
+func (s *Session) modeCommand(arg string) error {
+	if arg == "" {
+		s.ui.PrintInfo(fmt.Sprintf("Permission mode: %s (modes: default, acceptEdits, plan, bypass)",
+			s.permissionManager.Mode()))
+		return nil
+	}
+
+	mode, err := common.ParsePermissionMode(arg)
+	if err != nil {
+		return err
+	}
+	s.setMode(mode)
+	return nil
+}
+
+// CycleMode switches to the next permission mode
+func (s *Session) CycleMode() {
+	s.setMode(s.permissionManager.Mode().Next())
+}
+
+func (s *Session) setMode(mode common.PermissionMode) {
+	s.permissionManager.SetMode(mode)
+	s.ui.SetMode(mode)
+	s.ui.PrintInfo(fmt.Sprintf("Permission mode: %s", mode))
+}
+
+// withModeNote tells the model about the permission mode in a user message.
+// In plan mode every message reminds it, and the first message after plan
+// mode says that changes are allowed again.
+func (s *Session) withModeNote(message string) string {
+	mode := s.permissionManager.Mode()
+	defer func() { s.notedMode = mode }()
+
+	switch {
+	case mode == common.ModePlan:
+		return message + "\n\n<system-reminder>" + common.PlanModeReminder + "</system-reminder>"
+	case s.notedMode == common.ModePlan:
+		return message + "\n\n<system-reminder>Plan mode is off, you may now edit files and run commands.</system-reminder>"
+	}
+	return message
+}
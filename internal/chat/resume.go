@@ -0,0 +1,120 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// beforeRequest runs before every request of the session's agent. It saves
+// the messages added since the last request and compacts the conversation
+// when it fills the context window.
+func (s *Session) beforeRequest(ctx context.Context) error {
+	s.saveTranscript()
+
+	if !s.config.Context.AutoCompact {
+		return nil
+	}
+	return s.compactIfNeeded(ctx)
+}
+
+// saveTranscript appends new messages to the session's transcript
+func (s *Session) saveTranscript() {
+	if s.transcript == nil {
+		return
+	}
+	if err := s.transcript.Save(s.agent.Messages[1:]); err != nil {
+		s.ui.PrintError(fmt.Sprintf("Failed to save session: %v", err))
+	}
+}
+
+// resumeLatest resumes the most recent session saved for the working directory
+func (s *Session) resumeLatest() error {
+	sessions, err := s.savedSessions()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		return fmt.Errorf("no saved session for %s", s.workingDir)
+	}
+	return s.resume(sessions[0])
+}
+
+// resumeCommand handles /resume. Without an argument it lists the sessions
+// saved for the working directory and asks which one to resume.
+func (s *Session) resumeCommand(arg string) error {
+	sessions, err := s.savedSessions()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		s.ui.PrintInfo("No saved sessions for this directory")
+		return nil
+	}
+
+	if arg == "" {
+		s.ui.PrintInfo("Saved sessions:\n" + formatSessions(sessions))
+		arg = s.ui.AskInput("Session to resume (empty to cancel): ")
+		if arg == "" {
+			return nil
+		}
+	}
+
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(sessions) {
+		return fmt.Errorf("no session %q, choose 1 to %d", arg, len(sessions))
+	}
+	return s.resume(sessions[n-1])
+}
+
+// savedSessions lists the sessions saved for the working directory, other
+// than the current one
+func (s *Session) savedSessions() ([]SessionInfo, error) {
+	if s.sessionsDir == "" {
+		return nil, fmt.Errorf("sessions are not saved")
+	}
+
+	sessions, err := listSessions(s.sessionsDir, s.workingDir)
+	if err != nil {
+		return nil, fmt.Errorf("listing sessions: %w", err)
+	}
+
+	others := sessions[:0]
+	for _, info := range sessions {
+		if s.transcript == nil || info.ID != s.transcript.info.ID {
+			others = append(others, info)
+		}
+	}
+	return others, nil
+}
+
+// resume replaces the conversation with a saved session and continues
+// saving to it
+func (s *Session) resume(info SessionInfo) error {
+	transcript, messages, err := openTranscript(info.Path)
+	if err != nil {
+		return fmt.Errorf("resuming session: %w", err)
+	}
+
+	s.transcript = transcript
+	s.agent.RestoreMessages(messages)
+	if err := s.checkpoints.open(transcript.checkpointsDir()); err != nil {
+		s.ui.PrintError(fmt.Sprintf("Can't load the checkpoints of the session: %v", err))
+	}
+	// Keep the turns of new checkpoints apart from the loaded ones
+	s.turn = max(s.turn, s.checkpoints.lastTurn())
+
+	// Replay the conversation
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "user" && msg.Content != "":
+			s.ui.PrintUserMessage(msg.Content)
+		case msg.Role == "assistant" && msg.Content != "":
+			s.ui.PrintAssistantMessage(msg.Content)
+		}
+	}
+
+	s.ui.PrintSuccess(fmt.Sprintf("Resumed session from %s (%d messages)",
+		info.Updated.Format("2006-01-02 15:04"), len(messages)))
+	return nil
+}
@@ -11,9 +11,11 @@ import (
 	"github.com/recrsn/coder/internal/platform"
 	"github.com/recrsn/coder/internal/tools"
 	"github.com/recrsn/coder/internal/ui"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -23,17 +25,36 @@ type Session struct {
 	config            config.Config
 	registry          *tools.Registry
 	agent             *llm.Agent
-	client            *llm.Client
+	client            llm.Provider
 	history           []string
 	historyFile       string
 	apiLogger         llm.APILogger
 	permissionManager *common.PermissionManager
+	usage             *llm.UsageTracker
+	// transcript saves the conversation so that it can be resumed, nil if
+	// there is nowhere to save it
+	transcript  *Transcript
+	sessionsDir string
+	workingDir  string
+	// outputMu serializes the output of tool calls running concurrently
+	outputMu sync.Mutex
+	// notedMode is the permission mode the model was last told about
+	notedMode common.PermissionMode
+	// shell runs the shell commands of the session, nil if there is none
+	shell *tools.Shell
+	// turn counts the user messages, checkpoints are grouped by it
+	turn        int
+	checkpoints checkpointStore
+	// changes are the files tools changed, for /diff and the turn summary
+	changes changeTracker
+	// undoneFiles were restored by /undo since the last user message
+	undoneFiles []string
 	// For cancellation
 	cancelFunc context.CancelFunc
 }
 
 // NewSession creates a new chat session
-func NewSession(userInterface ui.UserInterface, cfg config.Config, registry *tools.Registry, client *llm.Client, permissionManager *common.PermissionManager) (*Session, error) {
+func NewSession(userInterface ui.UserInterface, cfg config.Config, registry *tools.Registry, client llm.Provider, permissionManager *common.PermissionManager) (*Session, error) {
 	// Set up history file in config directory
 	userConfigDir, err := os.UserConfigDir()
 	if err != nil {
@@ -49,6 +70,18 @@ func NewSession(userInterface ui.UserInterface, cfg config.Config, registry *too
 		fmt.Printf("Warning: couldn't create config directory: %v\n", err)
 	}
 
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("getting working directory: %w", err)
+	}
+
+	sessionsDir := ""
+	if dirs, err := platform.GetDirectories("coder"); err == nil {
+		sessionsDir = filepath.Join(dirs.Data, "sessions")
+	} else {
+		fmt.Printf("Warning: sessions won't be saved: %v\n", err)
+	}
+
 	session := &Session{
 		ui:                userInterface,
 		config:            cfg,
@@ -58,16 +91,30 @@ func NewSession(userInterface ui.UserInterface, cfg config.Config, registry *too
 		historyFile:       historyFile,
 		apiLogger:         apiLogger,
 		permissionManager: permissionManager,
+		usage:             newUsageTracker(cfg.Usage),
+		sessionsDir:       sessionsDir,
+		workingDir:        workingDir,
+	}
+	if sessionsDir != "" {
+		session.transcript = newTranscript(sessionsDir, workingDir, selectModel("chat", cfg).Model)
+		session.checkpoints.dir = session.transcript.checkpointsDir()
 	}
 
 	return session, nil
 }
 
-// Start starts the chat session
-func (s *Session) Start() error {
+// Start starts the chat session. With continueLatest it first resumes the
+// most recent session saved for the working directory.
+func (s *Session) Start(continueLatest bool) error {
 	s.ui.ShowHeader()
 	s.ui.PrintSuccess("Welcome to Coder! Type your programming questions or /help for commands.")
 
+	if continueLatest {
+		if err := s.resumeLatest(); err != nil {
+			s.ui.PrintError(fmt.Sprintf("Error resuming session: %v", err))
+		}
+	}
+
 	// Load history from file
 	s.loadHistory()
 
@@ -97,7 +144,7 @@ func (s *Session) Start() error {
 		// Display user message
 		s.ui.PrintUserMessage(userInput)
 
-		s.agent.AddMessage("user", userInput)
+		s.agent.AddMessage("user", s.withUndoNote(s.withModeNote(userInput)))
 		s.addToHistory(userInput)
 		s.saveHistory()
 
@@ -132,13 +179,69 @@ func (s *Session) handleCommand(cmd string) error {
 		s.ui.ClearScreen()
 		return nil
 	case "/summarize":
-		// Summarize previous messages and add to context
-		summary, err := s.SummarizeMessages()
+		// Replace the whole conversation with its summary
+		ctx, cancel := s.commandContext()
+		defer cancel()
+		summary, summarized, err := s.compact(ctx, 0)
 		if err != nil {
 			return fmt.Errorf("summarizing messages: %w", err)
 		}
+		if summarized == 0 {
+			s.ui.PrintInfo("Nothing to summarize")
+			return nil
+		}
 		s.ui.PrintAssistantMessage(summary)
-		s.ui.PrintSuccess("Conversation summarized and added to context")
+		s.ui.PrintSuccess("Conversation summarized and replaced with the summary")
+		return nil
+	case "/compact":
+		ctx, cancel := s.commandContext()
+		defer cancel()
+		_, compacted, err := s.compact(ctx, s.config.Context.KeepTurns)
+		if err != nil {
+			return fmt.Errorf("compacting conversation: %w", err)
+		}
+		if compacted == 0 {
+			s.ui.PrintInfo("Nothing to compact")
+			return nil
+		}
+		s.ui.PrintSuccess(fmt.Sprintf("Compacted %d messages into a summary", compacted))
+		return nil
+	case "/resume":
+		arg := ""
+		if len(parts) > 1 {
+			arg = strings.TrimSpace(parts[1])
+		}
+		return s.resumeCommand(arg)
+	case "/add-dir":
+		arg := ""
+		if len(parts) > 1 {
+			arg = strings.TrimSpace(parts[1])
+		}
+		return s.addDirCommand(arg)
+	case "/mode":
+		arg := ""
+		if len(parts) > 1 {
+			arg = strings.TrimSpace(parts[1])
+		}
+		return s.modeCommand(arg)
+	case "/jobs":
+		arg := ""
+		if len(parts) > 1 {
+			arg = strings.TrimSpace(parts[1])
+		}
+		return s.jobsCommand(arg)
+	case "/undo":
+		arg := ""
+		if len(parts) > 1 {
+			arg = strings.TrimSpace(parts[1])
+		}
+		return s.undoCommand(arg)
+	case "/checkpoints":
+		return s.checkpointsCommand()
+	case "/diff":
+		return s.diffCommand()
+	case "/cost":
+		s.ui.PrintInfo(formatUsage(s.usage.Summary()))
 		return nil
 	case "/tools":
 		// List available tools
@@ -157,6 +260,9 @@ func (s *Session) handleCommand(cmd string) error {
 
 // processUserMessage processes a user message and gets a response
 func (s *Session) processUserMessage() error {
+	s.turn++
+	s.changes.startTurn()
+
 	// Create a new context that can be cancelled
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -166,6 +272,8 @@ func (s *Session) processUserMessage() error {
 	// Start the conversation flow, which will handle all tool calls
 	// and yield the final response only when the conversation is complete
 	_, err := s.agent.Run(ctx)
+	s.saveTranscript()
+	s.printTurnSummary()
 
 	if err != nil {
 		s.ui.PrintError(fmt.Sprintf("Error processing message: %v", err))
@@ -189,17 +297,23 @@ func (s *Session) HandleToolCalls(ctx context.Context, toolName string, args map
 	tool, ok := s.registry.Get(toolName)
 	if !ok {
 		errorMsg := fmt.Sprintf("Tool not found: %s", toolName)
+		s.outputMu.Lock()
 		s.ui.PrintError(errorMsg)
+		s.outputMu.Unlock()
 		return errorMsg, nil
 	}
 
 	// Create permission request
 	result := tool.Explain(args)
+	command, paths := tool.PermissionSubjects(args)
 	request := common.PermissionRequest{
 		ToolName:  toolName,
 		Arguments: args,
 		Title:     result.Title,
 		Context:   result.Context,
+		Command:   command,
+		Paths:     paths,
+		ReadOnly:  !tool.Modifies(),
 	}
 
 	response := s.permissionManager.RequestPermission(request)
@@ -207,7 +321,35 @@ func (s *Session) HandleToolCalls(ctx context.Context, toolName string, args map
 	alternate := response.AlternateAction
 
 	if execute {
-		result, err := tool.Run(args)
+		// Save the files the call changes, so that /undo can restore them
+		var cp *checkpoint
+		if tool.Modifies() && len(paths) > 0 {
+			if saved, err := snapshot(s.turn, result.Title, paths); err == nil {
+				cp = &saved
+			} else {
+				s.outputMu.Lock()
+				s.ui.PrintError(fmt.Sprintf("Can't save a checkpoint, this change can't be undone: %v", err))
+				s.outputMu.Unlock()
+			}
+		}
+
+		ctx = tools.WithOutput(ctx, func(text string) {
+			s.outputMu.Lock()
+			defer s.outputMu.Unlock()
+			s.ui.PrintToolOutput(toolName, text)
+		})
+		result, err := tool.Run(ctx, args)
+		if err == nil && cp != nil {
+			s.changes.record(cp.files)
+			if err := s.checkpoints.add(*cp); err != nil {
+				s.outputMu.Lock()
+				s.ui.PrintError(fmt.Sprintf("Can't save a checkpoint, this change can't be undone: %v", err))
+				s.outputMu.Unlock()
+			}
+		}
+
+		s.outputMu.Lock()
+		defer s.outputMu.Unlock()
 
 		// Print result or error
 		if err != nil {
@@ -219,6 +361,10 @@ func (s *Session) HandleToolCalls(ctx context.Context, toolName string, args map
 		}
 	}
 
+	s.outputMu.Lock()
+	s.ui.PrintToolCall(toolName, args, "", fmt.Errorf("permission denied"))
+	s.outputMu.Unlock()
+
 	// If the user chooses not to execute, we can either return an alternate response
 	return "The user doesn't want to proceed with this tool use. " +
 		"The tool use was rejected (eg. if it was a file edit, the new_string was NOT written to the file)." +
@@ -315,7 +461,13 @@ func (s *Session) Exit() {
 	}
 
 	s.ui.PrintSuccess("Goodbye!")
+	if s.shell != nil {
+		s.shell.Close()
+	}
 	s.saveHistory()
+	if s.agent != nil {
+		s.saveTranscript()
+	}
 	os.Exit(0)
 }
 
@@ -324,7 +476,35 @@ func (s *Session) HandleMessage(message string) {
 	s.ui.PrintAssistantMessage(message)
 }
 
+// HandleStreamDelta handles streamed text from the agent
+func (s *Session) HandleStreamDelta(delta string) {
+	s.ui.PrintAssistantDelta(delta)
+}
+
+// HandleRetry reports that a failed API request will be retried
+func (s *Session) HandleRetry(attempt, maxRetries int, delay time.Duration, err error) {
+	seconds := int(math.Ceil(delay.Seconds()))
+	s.ui.PrintInfo(fmt.Sprintf("%v, retrying in %ds (retry %d of %d)", err, seconds, attempt, maxRetries))
+}
+
 // SetAgent sets the agent for this session
 func (s *Session) SetAgent(agent *llm.Agent) {
+	agent.SetUsageTracker(s.usage)
+	agent.SetParallelTools(s.registry.IsReadOnly, s.config.Tools.ParallelWorkers)
+	agent.SetBeforeRequestCallback(s.beforeRequest)
 	s.agent = agent
 }
+
+// SetShell sets the shell of the session, which is stopped with its jobs on
+// exit
+func (s *Session) SetShell(shell *tools.Shell) {
+	s.shell = shell
+	shell.OnJobsChange(func() {
+		s.ui.SetJobs(shell.RunningJobs())
+	})
+}
+
+// UsageTracker returns the tracker that records the token usage of this session
+func (s *Session) UsageTracker() *llm.UsageTracker {
+	return s.usage
+}
@@ -2,76 +2,252 @@ package chat
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/recrsn/coder/internal/chat/prompts"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/recrsn/coder/internal/llm"
 )
 
+// Limits applied when serializing a conversation for the summary model
+const (
+	// maxToolOutputChars is the length above which tool output is truncated
+	maxToolOutputChars = 2000
+	// maxArgumentChars is the length above which tool call arguments are truncated
+	maxArgumentChars = 200
+	// maxSummaryRounds bounds how often partial summaries are summarized again
+	maxSummaryRounds = 3
+)
+
 // MessageSummary represents a summarized version of the chat history
 type MessageSummary struct {
 	Summary      string
 	LastUpdateAt time.Time
 }
 
-// SummarizeMessages generates a summary of previous messages in the conversation
-// focusing on extracting key points and condensing them into a concise summary.
-func (s *Session) SummarizeMessages() (string, error) {
-	// Create a context that can be cancelled
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Prepare messages for summarization
-	// We'll use a special system message to instruct the LLM to create a summary
-	summaryPrompt := []llm.Message{
-		{
-			Role:    "system",
-			Content: prompts.RenderSummaryPrompt(),
-		},
+// SummarizeMessages generates a summary of messages focusing on extracting
+// key points and condensing them into a concise summary.
+func (s *Session) SummarizeMessages(ctx context.Context, messages []llm.Message) (string, error) {
+	cfg := selectModel("summary", s.config)
+	summarizer := &summarizer{
+		client: s.client,
+		model:  cfg,
+		window: s.config.Context.WindowFor(cfg.Model),
+		usage:  s.usage,
 	}
 
-	// Add a final user message asking for the summary
-	summaryPrompt = append(summaryPrompt, llm.Message{
-		Role: "user",
-		Content: "Please summarize our conversation so far into a concise, " +
-			"factual summary",
-	})
+	spinner := s.ui.StartSpinner("Generating conversation summary...")
+	summary, err := summarizer.summarize(ctx, messages)
+	if err != nil {
+		s.ui.StopSpinnerFail(spinner, "Failed to generate summary")
+		return "", fmt.Errorf("failed to generate summary: %w", err)
+	}
+	s.ui.StopSpinner(spinner, "Summary generated")
+
+	return summary, nil
+}
+
+// summarizer summarizes a conversation with the summary model. Conversations
+// that don't fit its context window are split into chunks that are
+// summarized separately, and the partial summaries are then summarized
+// together.
+type summarizer struct {
+	client llm.Provider
+	model  llm.ModelConfig
+	// window is the context window of the summary model in tokens
+	window int
+	usage  *llm.UsageTracker
+}
+
+// summarize serializes messages into a transcript and summarizes it
+func (s *summarizer) summarize(ctx context.Context, messages []llm.Message) (string, error) {
+	entries := serializeTranscript(messages)
+	if len(entries) == 0 {
+		return "", fmt.Errorf("no messages to summarize")
+	}
+
+	// Leave room for the summary prompt and the summary itself. A window of
+	// zero means the model's window is unknown, so nothing is chunked.
+	budget := s.window / 2
+
+	for round := 1; ; round++ {
+		chunks := chunkTranscript(entries, budget)
+		if len(chunks) == 1 {
+			return s.request(ctx, strings.Join(chunks[0], "\n\n"), "")
+		}
+		if round == maxSummaryRounds {
+			// The partial summaries still don't fit, so drop from the middle
+			return s.request(ctx, truncateMiddle(strings.Join(entries, "\n\n"), budget*3), "")
+		}
+
+		partials := make([]string, 0, len(chunks))
+		for i, chunk := range chunks {
+			note := fmt.Sprintf("This is part %d of %d of the conversation. Summarize only this part.", i+1, len(chunks))
+			partial, err := s.request(ctx, strings.Join(chunk, "\n\n"), note)
+			if err != nil {
+				return "", fmt.Errorf("summarizing part %d of %d: %w", i+1, len(chunks), err)
+			}
+			partials = append(partials, fmt.Sprintf("Summary of part %d of the conversation:\n%s", i+1, partial))
+		}
+		entries = partials
+	}
+}
+
+// request asks the summary model to summarize a transcript
+func (s *summarizer) request(ctx context.Context, transcript, note string) (string, error) {
+	var content strings.Builder
+	content.WriteString("Here is the transcript of our conversation:\n\n<transcript>\n")
+	content.WriteString(transcript)
+	content.WriteString("\n</transcript>\n\n")
+	if note != "" {
+		content.WriteString(note + " ")
+	}
+	content.WriteString("Please summarize the conversation into a concise, factual summary.")
 
-	cfg := selectModel("summary", s.config)
-	// Create chat completion request
 	req := llm.ChatCompletionRequest{
-		Model:       cfg.Model,
-		Messages:    summaryPrompt,
-		Temperature: cfg.Temperature,
+		Model: s.model.Model,
+		Messages: []llm.Message{
+			{
+				Role:    "system",
+				Content: prompts.RenderSummaryPrompt(),
+			},
+			{
+				Role:    "user",
+				Content: content.String(),
+			},
+		},
+		Temperature: s.model.Temperature,
 	}
 
-	// Send request to LLM
-	spinner := s.ui.StartSpinner("Generating conversation summary...")
 	resp, err := s.client.CreateChatCompletion(ctx, req)
 	if err != nil {
-		s.ui.StopSpinnerFail(spinner, "Failed to generate summary")
-		return "", fmt.Errorf("failed to generate summary: %w", err)
+		return "", err
+	}
+	if s.usage != nil {
+		s.usage.Record("Summary", s.model.Model, resp.Usage)
 	}
 
-	// Check if we got a valid response
 	if len(resp.Choices) == 0 {
-		s.ui.StopSpinnerFail(spinner, "No summary generated")
 		return "", fmt.Errorf("no summary generated")
 	}
+	return resp.Choices[0].Message.Content, nil
+}
 
-	// Extract the summary
-	summary := resp.Choices[0].Message.Content
-	s.ui.StopSpinner(spinner, "Summary generated")
+// serializeTranscript renders messages as plain text entries, one per
+// message. Tool calls are condensed to their name and arguments and long tool
+// output is truncated, since the summary needs what was done rather than
+// every line of it.
+func serializeTranscript(messages []llm.Message) []string {
+	toolNames := make(map[string]string)
+	var entries []string
+
+	for _, msg := range messages {
+		var entry strings.Builder
+
+		switch msg.Role {
+		case "system":
+			continue
+		case "user":
+			entry.WriteString("User: " + msg.Content)
+		case "assistant":
+			entry.WriteString("Assistant:")
+			if msg.Content != "" {
+				entry.WriteString(" " + msg.Content)
+			}
+			for _, toolCall := range msg.ToolCalls {
+				toolNames[toolCall.ID] = toolCall.Function.Name
+				entry.WriteString(fmt.Sprintf("\n[called %s(%s)]", toolCall.Function.Name,
+					truncateMiddle(condenseArguments(toolCall.Function.Arguments), maxArgumentChars)))
+			}
+		case "tool":
+			name := toolNames[msg.ToolCallID]
+			if name == "" {
+				name = "tool"
+			}
+			entry.WriteString(fmt.Sprintf("[%s result]\n%s", name, truncateMiddle(msg.Content, maxToolOutputChars)))
+		default:
+			entry.WriteString(msg.Role + ": " + msg.Content)
+		}
+
+		entries = append(entries, strings.TrimSpace(entry.String()))
+	}
+
+	return entries
+}
+
+// condenseArguments renders JSON tool call arguments as key=value pairs
+func condenseArguments(arguments string) string {
+	var args map[string]any
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return arguments
+	}
+
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, args[key]))
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// truncateMiddle shortens text to about limit characters, keeping its start
+// and end, which usually carry the command and its outcome
+func truncateMiddle(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+
+	head := strings.ToValidUTF8(text[:limit*2/3], "")
+	tail := strings.ToValidUTF8(text[len(text)-limit/3:], "")
+	omitted := len(text) - len(head) - len(tail)
+	return fmt.Sprintf("%s\n... [%d characters omitted] ...\n%s", head, omitted, tail)
+}
+
+// chunkTranscript splits entries into chunks of about budget tokens each.
+// Entries larger than the budget are truncated to fit.
+func chunkTranscript(entries []string, budget int) [][]string {
+	var chunks [][]string
+	var chunk []string
+	tokens := 0
 
-	// Add the summary to the agent's context
-	s.agent.AddMessage("user", fmt.Sprintf(""+
+	for _, entry := range entries {
+		entryTokens := llm.EstimateMessageTokens(llm.Message{Content: entry})
+		if budget > 0 && entryTokens > budget {
+			entry = truncateMiddle(entry, budget*3)
+			entryTokens = llm.EstimateMessageTokens(llm.Message{Content: entry})
+		}
+
+		if len(chunk) > 0 && budget > 0 && tokens+entryTokens > budget {
+			chunks = append(chunks, chunk)
+			chunk = nil
+			tokens = 0
+		}
+		chunk = append(chunk, entry)
+		tokens += entryTokens
+	}
+
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// continuationMessage introduces the summary of an earlier conversation
+func continuationMessage(summary string) string {
+	return fmt.Sprintf(""+
 		"This session is being continued from a previous conversation."+
 		" The conversation is summarized below:"+
 		"\n%s\n"+
-		"Please continue the conversation from where we left it off without asking the any further questions."+
-		"Continue with the last task that you were asked to work on.",
+		"Please continue the conversation from where we left it off without asking any further questions."+
+		" Continue with the last task that you were asked to work on.",
 		summary,
-	))
-	return summary, nil
+	)
 }
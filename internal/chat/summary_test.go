@@ -0,0 +1,118 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/recrsn/coder/internal/llm"
+)
+
+// fakeProvider answers every request with a numbered summary and records the requests
+type fakeProvider struct {
+	requests []llm.ChatCompletionRequest
+}
+
+func (p *fakeProvider) CreateChatCompletion(_ context.Context, req llm.ChatCompletionRequest) (*llm.ChatCompletionResponse, error) {
+	p.requests = append(p.requests, req)
+	return &llm.ChatCompletionResponse{
+		Choices: []llm.ChatCompletionChoice{{
+			Message:      llm.Message{Role: "assistant", Content: fmt.Sprintf("summary %d", len(p.requests))},
+			FinishReason: "stop",
+		}},
+	}, nil
+}
+
+func (p *fakeProvider) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest, _ func(string)) (*llm.ChatCompletionResponse, error) {
+	return p.CreateChatCompletion(ctx, req)
+}
+
+func testConversation(output string) []llm.Message {
+	return []llm.Message{
+		{Role: "user", Content: "Fix the failing test in parser.go"},
+		{Role: "assistant", Content: "Let me run the tests.", ToolCalls: []llm.ToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: llm.FunctionCall{Name: "shell", Arguments: `{"command": "go test ./..."}`},
+		}}},
+		{Role: "tool", ToolCallID: "call_1", Content: output},
+		{Role: "assistant", Content: "The parser test fails on empty input."},
+	}
+}
+
+func TestSummarizeSendsTranscript(t *testing.T) {
+	provider := &fakeProvider{}
+	s := &summarizer{client: provider, model: llm.ModelConfig{Model: "lite"}, window: 100000}
+
+	output := "FAIL TestParse" + strings.Repeat("x", 10000) + "exit status 1"
+	summary, err := s.summarize(context.Background(), testConversation(output))
+	if err != nil {
+		t.Fatalf("summarize failed: %v", err)
+	}
+	if summary != "summary 1" {
+		t.Errorf("unexpected summary %q", summary)
+	}
+	if len(provider.requests) != 1 {
+		t.Fatalf("expected a single request, got %d", len(provider.requests))
+	}
+
+	req := provider.requests[0]
+	if req.Model != "lite" || len(req.Messages) != 2 || len(req.Tools) != 0 {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+
+	transcript := req.Messages[1].Content
+	for _, want := range []string{
+		"User: Fix the failing test in parser.go",
+		"[called shell(command=go test ./...)]",
+		"[shell result]",
+		"FAIL TestParse",
+		"exit status 1",
+		"characters omitted",
+		"The parser test fails on empty input.",
+	} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("expected transcript to contain %q:\n%s", want, transcript)
+		}
+	}
+	if len(transcript) > 4000 {
+		t.Errorf("expected long tool output to be truncated, transcript has %d characters", len(transcript))
+	}
+}
+
+func TestSummarizeChunksLongTranscripts(t *testing.T) {
+	provider := &fakeProvider{}
+	s := &summarizer{client: provider, model: llm.ModelConfig{Model: "lite"}, window: 1000}
+
+	var messages []llm.Message
+	for i := 0; i < 10; i++ {
+		messages = append(messages, testConversation(strings.Repeat("output ", 200))...)
+	}
+
+	summary, err := s.summarize(context.Background(), messages)
+	if err != nil {
+		t.Fatalf("summarize failed: %v", err)
+	}
+
+	if len(provider.requests) < 3 {
+		t.Fatalf("expected the transcript to be split into parts, got %d requests", len(provider.requests))
+	}
+	if summary != fmt.Sprintf("summary %d", len(provider.requests)) {
+		t.Errorf("expected the last request to combine the parts, got %q", summary)
+	}
+
+	first := provider.requests[0].Messages[1].Content
+	if !strings.Contains(first, "This is part 1 of") {
+		t.Errorf("expected the first request to summarize a part:\n%s", first)
+	}
+	last := provider.requests[len(provider.requests)-1].Messages[1].Content
+	if !strings.Contains(last, "Summary of part 1 of the conversation:\nsummary 1") {
+		t.Errorf("expected the last request to contain the partial summaries:\n%s", last)
+	}
+	for _, req := range provider.requests {
+		if tokens := llm.EstimateTokens(req.Messages[1:]); tokens > s.window {
+			t.Errorf("request of %d tokens exceeds the window of %d", tokens, s.window)
+		}
+	}
+}
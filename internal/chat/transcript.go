@@ -0,0 +1,239 @@
+package chat
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/recrsn/coder/internal/llm"
+)
+
+// Record types of a transcript file
+const (
+	recordSession = "session"
+	recordMessage = "message"
+	// recordReset discards the messages before it, e.g. after compaction
+	recordReset = "reset"
+)
+
+// SessionInfo describes a saved session
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	WorkingDir string    `json:"cwd"`
+	Model      string    `json:"model"`
+	Created    time.Time `json:"created"`
+
+	// Updated, Title and Messages are derived from the saved messages
+	Updated  time.Time `json:"-"`
+	Title    string    `json:"-"`
+	Messages int       `json:"-"`
+	Path     string    `json:"-"`
+}
+
+// transcriptRecord is one line of a transcript file
+type transcriptRecord struct {
+	Type    string       `json:"type"`
+	Time    time.Time    `json:"time"`
+	Session *SessionInfo `json:"session,omitempty"`
+	Message *llm.Message `json:"message,omitempty"`
+}
+
+// Transcript persists the messages of a session as JSONL. Messages are
+// appended as the conversation grows, so that a crash loses at most the
+// current request. The file is created with the first message.
+type Transcript struct {
+	info SessionInfo
+	// saved is the number of messages written since the last reset
+	saved int
+}
+
+// newTranscript creates the transcript of a new session in dir
+func newTranscript(dir, workingDir, model string) *Transcript {
+	now := time.Now()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	id := now.Format("20060102-150405") + "-" + hex.EncodeToString(b)
+
+	return &Transcript{
+		info: SessionInfo{
+			ID:         id,
+			WorkingDir: workingDir,
+			Model:      model,
+			Created:    now,
+			Path:       filepath.Join(dir, id+".jsonl"),
+		},
+	}
+}
+
+// Save appends the messages that were added since the last save. messages
+// excludes the system prompt.
+func (t *Transcript) Save(messages []llm.Message) error {
+	if len(messages) < t.saved {
+		return t.Reset(messages)
+	}
+	if len(messages) == t.saved {
+		return nil
+	}
+
+	var records []transcriptRecord
+	if _, err := os.Stat(t.info.Path); os.IsNotExist(err) {
+		records = append(records, transcriptRecord{Type: recordSession, Time: t.info.Created, Session: &t.info})
+	}
+	records = append(records, messageRecords(messages[t.saved:])...)
+
+	if err := t.write(records); err != nil {
+		return err
+	}
+	t.saved = len(messages)
+	return nil
+}
+
+// Reset records that messages replace everything saved so far
+func (t *Transcript) Reset(messages []llm.Message) error {
+	t.saved = 0
+	records := []transcriptRecord{{Type: recordReset, Time: time.Now()}}
+	if _, err := os.Stat(t.info.Path); os.IsNotExist(err) {
+		records[0] = transcriptRecord{Type: recordSession, Time: t.info.Created, Session: &t.info}
+	}
+
+	if err := t.write(append(records, messageRecords(messages)...)); err != nil {
+		return err
+	}
+	t.saved = len(messages)
+	return nil
+}
+
+func (t *Transcript) write(records []transcriptRecord) error {
+	if err := os.MkdirAll(filepath.Dir(t.info.Path), 0755); err != nil {
+		return fmt.Errorf("creating sessions directory: %w", err)
+	}
+
+	file, err := os.OpenFile(t.info.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("opening transcript: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("writing transcript: %w", err)
+		}
+	}
+	return writer.Flush()
+}
+
+func messageRecords(messages []llm.Message) []transcriptRecord {
+	now := time.Now()
+	records := make([]transcriptRecord, len(messages))
+	for i := range messages {
+		records[i] = transcriptRecord{Type: recordMessage, Time: now, Message: &messages[i]}
+	}
+	return records
+}
+
+// checkpointsDir returns the directory the checkpoints of the session are
+// saved in, next to the transcript
+func (t *Transcript) checkpointsDir() string {
+	return strings.TrimSuffix(t.info.Path, ".jsonl") + ".checkpoints"
+}
+
+// openTranscript loads a saved session. New messages are appended to it.
+func openTranscript(path string) (*Transcript, []llm.Message, error) {
+	info, messages, err := readTranscript(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Transcript{info: info, saved: len(messages)}, messages, nil
+}
+
+// readTranscript replays a transcript file into its session info and messages
+func readTranscript(path string) (SessionInfo, []llm.Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return SessionInfo{}, nil, fmt.Errorf("opening transcript: %w", err)
+	}
+	defer file.Close()
+
+	var info SessionInfo
+	var messages []llm.Message
+
+	scanner := bufio.NewScanner(file)
+	// Tool results can make for long lines
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var record transcriptRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// A crash may leave a partial last line
+			continue
+		}
+
+		switch record.Type {
+		case recordSession:
+			if record.Session != nil {
+				info = *record.Session
+			}
+		case recordReset:
+			messages = nil
+		case recordMessage:
+			if record.Message != nil {
+				messages = append(messages, *record.Message)
+				if info.Title == "" && record.Message.Role == "user" {
+					info.Title = record.Message.Content
+				}
+			}
+		}
+		info.Updated = record.Time
+	}
+	if err := scanner.Err(); err != nil {
+		return SessionInfo{}, nil, fmt.Errorf("reading transcript: %w", err)
+	}
+
+	info.Messages = len(messages)
+	info.Path = path
+	return info, messages, nil
+}
+
+// listSessions returns the sessions saved in dir for workingDir, most
+// recently updated first
+func listSessions(dir, workingDir string) ([]SessionInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []SessionInfo
+	for _, path := range paths {
+		info, _, err := readTranscript(path)
+		if err != nil || info.WorkingDir != workingDir || info.Messages == 0 {
+			continue
+		}
+		sessions = append(sessions, info)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Updated.After(sessions[j].Updated)
+	})
+	return sessions, nil
+}
+
+// formatSessions renders saved sessions as a numbered list for /resume
+func formatSessions(sessions []SessionInfo) string {
+	var b strings.Builder
+	for i, info := range sessions {
+		title := strings.Join(strings.Fields(info.Title), " ")
+		if len(title) > 60 {
+			title = title[:57] + "..."
+		}
+		fmt.Fprintf(&b, "%2d. %s  %3d messages  %s\n", i+1, info.Updated.Format("2006-01-02 15:04"), info.Messages, title)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,80 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/recrsn/coder/internal/llm"
+)
+
+func TestTranscriptRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	transcript := newTranscript(dir, "/work/project", "gpt-4o")
+
+	messages := []llm.Message{
+		{Role: "user", Content: "List the files"},
+		{Role: "assistant", ToolCalls: []llm.ToolCall{{ID: "call_1", Type: "function", Function: llm.FunctionCall{Name: "ls", Arguments: `{"path": "."}`}}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "main.go"},
+	}
+	if err := transcript.Save(messages[:1]); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if err := transcript.Save(messages); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	info, loaded, err := readTranscript(transcript.info.Path)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if len(loaded) != 3 || loaded[1].ToolCalls[0].Function.Name != "ls" || loaded[2].ToolCallID != "call_1" {
+		t.Fatalf("unexpected messages: %+v", loaded)
+	}
+	if info.WorkingDir != "/work/project" || info.Model != "gpt-4o" || info.Title != "List the files" {
+		t.Errorf("unexpected session info: %+v", info)
+	}
+
+	// Compaction replaces the saved messages
+	compacted := []llm.Message{{Role: "user", Content: "summary"}, messages[2]}
+	if err := transcript.Reset(compacted); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	resumed, loaded, err := openTranscript(transcript.info.Path)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].Content != "summary" {
+		t.Fatalf("expected the reset to replace the messages, got %+v", loaded)
+	}
+
+	// A resumed transcript only appends new messages
+	if err := resumed.Save(append(loaded, llm.Message{Role: "assistant", Content: "done"})); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if _, loaded, _ = readTranscript(transcript.info.Path); len(loaded) != 3 {
+		t.Errorf("expected 3 messages after resuming, got %d", len(loaded))
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, workingDir := range []string{"/work/a", "/work/b", "/work/a"} {
+		transcript := newTranscript(dir, workingDir, "gpt-4o")
+		if err := transcript.Save([]llm.Message{{Role: "user", Content: workingDir}}); err != nil {
+			t.Fatalf("save failed: %v", err)
+		}
+	}
+	// A session without messages is never written
+	newTranscript(dir, "/work/a", "gpt-4o")
+
+	sessions, err := listSessions(dir, "/work/a")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions for /work/a, got %d", len(sessions))
+	}
+	if sessions[0].Updated.Before(sessions[1].Updated) {
+		t.Errorf("expected the most recent session first")
+	}
+}
@@ -0,0 +1,71 @@
+package chat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/recrsn/coder/internal/config"
+	"github.com/recrsn/coder/internal/llm"
+)
+
+// newUsageTracker creates a usage tracker priced from the configuration
+func newUsageTracker(cfg config.UsageConfig) *llm.UsageTracker {
+	prices := make(map[string]llm.ModelPrice, len(cfg.Pricing))
+	for _, pricing := range cfg.Pricing {
+		prices[pricing.Model] = llm.ModelPrice{
+			Input:  pricing.Input,
+			Output: pricing.Output,
+		}
+	}
+	return llm.NewUsageTracker(prices, cfg.Budget)
+}
+
+// formatUsage renders a usage summary as a table for the /cost command
+func formatUsage(summary llm.UsageSummary) string {
+	if summary.Total.Requests == 0 {
+		return "No requests made yet"
+	}
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+
+	writeSection := func(title string, totals map[string]llm.UsageTotal) {
+		fmt.Fprintf(w, "%s\tRequests\tPrompt\tCompletion\tCost\n", title)
+
+		names := make([]string, 0, len(totals))
+		for name := range totals {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			writeUsageRow(w, name, totals[name])
+		}
+		fmt.Fprintln(w, "\t\t\t\t")
+	}
+
+	writeSection("Agent", summary.ByAgent)
+	writeSection("Model", summary.ByModel)
+	writeUsageRow(w, "Session total", summary.Total)
+	_ = w.Flush()
+
+	if summary.Budget > 0 {
+		fmt.Fprintf(&b, "\nBudget: $%.2f of $%.2f used", summary.Total.Cost, summary.Budget)
+	}
+	if summary.Total.Unpriced > 0 {
+		fmt.Fprintf(&b, "\n%d request(s) used models without a price in usage.pricing and are not included in the cost",
+			summary.Total.Unpriced)
+	}
+
+	return b.String()
+}
+
+func writeUsageRow(w *tabwriter.Writer, name string, total llm.UsageTotal) {
+	cost := fmt.Sprintf("$%.4f", total.Cost)
+	if total.Unpriced == total.Requests {
+		cost = "n/a"
+	}
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", name, total.Requests, total.PromptTokens, total.CompletionTokens, cost)
+}
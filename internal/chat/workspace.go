@@ -0,0 +1,23 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// addDirCommand handles /add-dir, which lets file tools access another
+// directory without asking. Without an argument it lists the workspace.
+func (s *Session) addDirCommand(arg string) error {
+	workspace := s.permissionManager.Workspace()
+	if arg == "" {
+		s.ui.PrintInfo("Workspace directories:\n" + strings.Join(workspace.Roots(), "\n"))
+		return nil
+	}
+
+	dir, err := workspace.AddDir(arg)
+	if err != nil {
+		return err
+	}
+	s.ui.PrintSuccess(fmt.Sprintf("Added %s to the workspace", dir))
+	return nil
+}
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NonInteractivePermissionHandler answers permission requests when no user
+// is available. It grants the tools it was given and denies everything else.
+type NonInteractivePermissionHandler struct {
+	allowed map[string]bool
+}
+
+// NewNonInteractivePermissionHandler creates a handler that allows only the named tools
+func NewNonInteractivePermissionHandler(allowedTools []string) *NonInteractivePermissionHandler {
+	allowed := make(map[string]bool, len(allowedTools))
+	for _, tool := range allowedTools {
+		allowed[tool] = true
+	}
+	return &NonInteractivePermissionHandler{allowed: allowed}
+}
+
+// RequestPermission grants allowed tools and denies the rest
+func (h *NonInteractivePermissionHandler) RequestPermission(request PermissionRequest) PermissionResponse {
+	if len(request.Outside) > 0 {
+		return PermissionResponse{
+			Granted: false,
+			AlternateAction: fmt.Sprintf("%s is outside the workspace and can't be accessed in this non-interactive run.",
+				strings.Join(request.Outside, ", ")),
+		}
+	}
+	if h.allowed[request.ToolName] {
+		return PermissionResponse{Granted: true}
+	}
+
+	return PermissionResponse{
+		Granted: false,
+		AlternateAction: fmt.Sprintf("The %s tool is not allowed in this non-interactive run. "+
+			"Complete the task without it, or explain what you would have done.", request.ToolName),
+	}
+}
@@ -1,6 +1,12 @@
 package common
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
 	"github.com/recrsn/coder/internal/config"
 )
 
@@ -9,19 +15,115 @@ type PermissionManager struct {
 	config        config.PermissionConfig
 	handler       PermissionHandler
 	defaultPolicy bool // Default policy if no specific rule exists
+	rules         []permissionRule
+	// workingDir and homeDir resolve the paths of rules and requests
+	workingDir string
+	homeDir    string
+	workspace  *Workspace
+	// mu makes concurrent tool calls ask the user one at a time
+	mu sync.Mutex
+	// sessionRules are the grants the user made for the rest of the session
+	sessionRules []permissionRule
+	mode         PermissionMode
+	// stateMu guards sessionRules and mode
+	stateMu  sync.RWMutex
+	saveRule func(rule config.PermissionRule)
 }
 
 // NewPermissionManager creates a new permission manager
 func NewPermissionManager(config config.PermissionConfig, handler PermissionHandler) *PermissionManager {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		workingDir = "."
+	}
+	if resolved, err := filepath.EvalSymlinks(workingDir); err == nil {
+		workingDir = resolved
+	}
+	homeDir, _ := os.UserHomeDir()
+	if resolved, err := filepath.EvalSymlinks(homeDir); err == nil && homeDir != "" {
+		homeDir = resolved
+	}
+
 	return &PermissionManager{
 		config:        config,
 		handler:       handler,
 		defaultPolicy: false, // Default to requiring permission
+		mode:          ModeDefault,
+		rules:         parsePermissionRules(config.Rules),
+		workingDir:    workingDir,
+		homeDir:       homeDir,
+		workspace:     NewWorkspace(workingDir),
 	}
 }
 
+// SetSaveRule sets the function that persists the rules the user always allows
+func (m *PermissionManager) SetSaveRule(save func(rule config.PermissionRule)) {
+	m.saveRule = save
+}
+
+// Workspace returns the directories file tools may access without asking
+func (m *PermissionManager) Workspace() *Workspace {
+	return m.workspace
+}
+
+// Mode returns the session's permission mode
+func (m *PermissionManager) Mode() PermissionMode {
+	m.stateMu.RLock()
+	defer m.stateMu.RUnlock()
+	return m.mode
+}
+
+// SetMode changes the session's permission mode
+func (m *PermissionManager) SetMode(mode PermissionMode) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	m.mode = mode
+}
+
 // RequestPermission handles a permission request
 func (m *PermissionManager) RequestPermission(request PermissionRequest) PermissionResponse {
+	request = m.resolvePaths(request)
+
+	mode := m.Mode()
+	if mode == ModePlan && !request.ReadOnly {
+		return PermissionResponse{
+			Granted:         false,
+			AlternateAction: PlanModeReminder,
+		}
+	}
+
+	// The first matching rule decides, ask rules override auto-approval but
+	// not the mode or the user's grants
+	action := m.ruleAction(m.rules, request)
+	switch action {
+	case config.PermissionAllow:
+		return PermissionResponse{Granted: true}
+	case config.PermissionDeny:
+		return PermissionResponse{
+			Granted: false,
+			AlternateAction: fmt.Sprintf("This %s call is denied by the permission rules. "+
+				"Don't retry it, find another way or ask the user.", request.ToolName),
+		}
+	}
+	if mode == ModeBypass {
+		return PermissionResponse{Granted: true}
+	}
+
+	// Only the user grants access outside the workspace
+	if len(request.Outside) > 0 {
+		return m.ask(request)
+	}
+
+	if m.sessionAllows(request) {
+		return PermissionResponse{Granted: true}
+	}
+	if mode == ModeAcceptEdits && editTools[request.ToolName] && len(request.Paths) > 0 {
+		return PermissionResponse{Granted: true}
+	}
+	if action == config.PermissionAsk {
+		return m.ask(request)
+	}
+
 	// Check if this tool is auto-approved
 	if autoApprove, ok := m.config.AutoApprove[request.ToolName]; ok && autoApprove {
 		return PermissionResponse{
@@ -30,9 +132,43 @@ func (m *PermissionManager) RequestPermission(request PermissionRequest) Permiss
 		}
 	}
 
-	// If not auto-approved and we have a UI handler, ask the user
+	return m.ask(request)
+}
+
+// ruleAction returns the action of the first of rules matching request, or
+// an empty string if none does. A command with a substitution that no rule
+// denies is asked about, as the commands it runs aren't known.
+func (m *PermissionManager) ruleAction(rules []permissionRule, request PermissionRequest) string {
+	for _, rule := range rules {
+		if rule.matches(request, m.workingDir, m.homeDir) {
+			return rule.action
+		}
+	}
+	if request.Command != "" {
+		if _, substitution := splitShellCommand(request.Command); substitution {
+			return config.PermissionAsk
+		}
+	}
+	return ""
+}
+
+// ask asks the handler, or applies the default policy without one
+func (m *PermissionManager) ask(request PermissionRequest) PermissionResponse {
+	// If we have a UI handler, ask the user
 	if m.handler != nil {
-		return m.handler.RequestPermission(request)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		// The user may have granted it while this request waited
+		if len(request.Outside) == 0 && m.sessionAllows(request) {
+			return PermissionResponse{Granted: true}
+		}
+
+		response := m.handler.RequestPermission(request)
+		if response.Granted && response.Scope != ScopeOnce {
+			m.grant(request, response.Scope)
+		}
+		return response
 	}
 
 	// If no UI handler, use the default policy
@@ -41,3 +177,49 @@ func (m *PermissionManager) RequestPermission(request PermissionRequest) Permiss
 		AlternateAction: "Permission denied by default policy",
 	}
 }
+
+// sessionAllows reports whether the user granted request for the session
+func (m *PermissionManager) sessionAllows(request PermissionRequest) bool {
+	m.stateMu.RLock()
+	defer m.stateMu.RUnlock()
+	return m.ruleAction(m.sessionRules, request) == config.PermissionAllow
+}
+
+// grant allows the calls covered by request's GrantRule for the session,
+// and saves the rule if the user always allows them
+func (m *PermissionManager) grant(request PermissionRequest, scope PermissionScope) {
+	rule := config.PermissionRule{Action: config.PermissionAllow, Rule: request.GrantRule()}
+	if rule.Rule == "" {
+		return
+	}
+
+	m.stateMu.Lock()
+	m.sessionRules = append(m.sessionRules, parsePermissionRules([]config.PermissionRule{rule})...)
+	m.stateMu.Unlock()
+
+	if scope == ScopeAlways && m.saveRule != nil {
+		m.saveRule(rule)
+	}
+}
+
+// resolvePaths replaces the paths of request by their resolved form and
+// lists those outside the workspace
+func (m *PermissionManager) resolvePaths(request PermissionRequest) PermissionRequest {
+	paths := make([]string, 0, len(request.Paths))
+	request.Outside = nil
+	for _, path := range request.Paths {
+		if strings.HasPrefix(path, "~/") && m.homeDir != "" {
+			path = filepath.Join(m.homeDir, path[2:])
+		}
+		resolved, err := ResolvePath(path, m.workingDir)
+		if err != nil {
+			resolved = path
+		}
+		paths = append(paths, resolved)
+		if !m.workspace.Contains(resolved) {
+			request.Outside = append(request.Outside, resolved)
+		}
+	}
+	request.Paths = paths
+	return request
+}
@@ -0,0 +1,60 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PermissionMode changes how a PermissionManager answers requests for the
+// whole session
+type PermissionMode string
+
+const (
+	// ModeDefault asks for every call that no rule or auto-approval grants
+	ModeDefault PermissionMode = "default"
+	// ModePlan denies every call that may change something, so the model can
+	// only investigate and propose a plan
+	ModePlan PermissionMode = "plan"
+	// ModeAcceptEdits also grants file edits inside the workspace
+	ModeAcceptEdits PermissionMode = "acceptEdits"
+	// ModeBypass grants every call that no rule denies
+	ModeBypass PermissionMode = "bypass"
+)
+
+// PermissionModes lists the modes in the order they are cycled through.
+// Bypass mode is last and never cycled into.
+var PermissionModes = []PermissionMode{ModeDefault, ModeAcceptEdits, ModePlan, ModeBypass}
+
+// editTools are the tools acceptEdits mode grants inside the workspace
+var editTools = map[string]bool{
+	"write":          true,
+	"search_replace": true,
+	"sed":            true,
+	"apply_patch":    true,
+}
+
+// PlanModeReminder tells the model what plan mode means
+const PlanModeReminder = "Plan mode is on: investigate with read-only tools and propose a plan, " +
+	"but don't edit files or run commands that change anything. The user switches modes to apply the plan."
+
+// ParsePermissionMode returns the mode with the given name, ignoring case
+func ParsePermissionMode(name string) (PermissionMode, error) {
+	for _, mode := range PermissionModes {
+		if strings.EqualFold(name, string(mode)) {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown permission mode %q, expected default, acceptEdits, plan or bypass", name)
+}
+
+// Next returns the mode after m when cycling through modes, skipping bypass
+func (m PermissionMode) Next() PermissionMode {
+	switch m {
+	case ModeDefault:
+		return ModeAcceptEdits
+	case ModeAcceptEdits:
+		return ModePlan
+	default:
+		return ModeDefault
+	}
+}
@@ -0,0 +1,76 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/recrsn/coder/internal/config"
+)
+
+func TestPermissionManager_Modes(t *testing.T) {
+	rules := []config.PermissionRule{
+		{Action: config.PermissionDeny, Rule: "shell(rm -rf *)"},
+		{Action: config.PermissionAllow, Rule: "shell(go test:*)"},
+	}
+
+	tests := []struct {
+		name     string
+		mode     PermissionMode
+		request  PermissionRequest
+		expected string
+	}{
+		{"default asks for edits", ModeDefault, PermissionRequest{ToolName: "write", Paths: []string{"a.go"}}, "ask"},
+		{"plan denies edits", ModePlan, PermissionRequest{ToolName: "write", Paths: []string{"a.go"}}, "deny"},
+		{"plan denies allowed commands", ModePlan, PermissionRequest{ToolName: "shell", Command: "go test ./..."}, "deny"},
+		{"plan grants read-only tools", ModePlan, PermissionRequest{ToolName: "read", Paths: []string{"a.go"}, ReadOnly: true}, "allow"},
+		{"accept edits grants edits in the workspace", ModeAcceptEdits, PermissionRequest{ToolName: "search_replace", Paths: []string{"src/a.go"}}, "allow"},
+		{"accept edits asks for edits outside the workspace", ModeAcceptEdits, PermissionRequest{ToolName: "write", Paths: []string{"/etc/hosts"}}, "ask"},
+		{"accept edits asks for commands", ModeAcceptEdits, PermissionRequest{ToolName: "shell", Command: "make"}, "ask"},
+		{"bypass grants commands", ModeBypass, PermissionRequest{ToolName: "shell", Command: "make"}, "allow"},
+		{"bypass keeps deny rules", ModeBypass, PermissionRequest{ToolName: "shell", Command: "rm -rf /"}, "deny"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &recordingPermissionHandler{}
+			manager := NewPermissionManager(config.PermissionConfig{
+				AutoApprove: map[string]bool{"read": true},
+				Rules:       rules,
+			}, handler)
+			manager.workingDir = "/work/project"
+			manager.workspace = &Workspace{roots: []string{"/work/project"}}
+			manager.SetMode(tc.mode)
+
+			response := manager.RequestPermission(tc.request)
+
+			actual := "deny"
+			switch {
+			case handler.asked:
+				actual = "ask"
+			case response.Granted:
+				actual = "allow"
+			}
+			if actual != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPermissionMode_Next(t *testing.T) {
+	mode := ModeDefault
+	var seen []PermissionMode
+	for range 4 {
+		mode = mode.Next()
+		seen = append(seen, mode)
+	}
+
+	expected := []PermissionMode{ModeAcceptEdits, ModePlan, ModeDefault, ModeAcceptEdits}
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, seen)
+		}
+	}
+	if ModeBypass.Next() != ModeDefault {
+		t.Errorf("Expected bypass to be followed by default, got %s", ModeBypass.Next())
+	}
+}
@@ -0,0 +1,249 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/recrsn/coder/internal/config"
+)
+
+// permissionRule is a parsed config.PermissionRule
+type permissionRule struct {
+	action  string
+	tool    string
+	pattern string
+}
+
+// parsePermissionRules parses rules, skipping invalid ones. LoadConfig has
+// already rejected those.
+func parsePermissionRules(rules []config.PermissionRule) []permissionRule {
+	parsed := make([]permissionRule, 0, len(rules))
+	for _, rule := range rules {
+		tool, pattern, err := config.ParsePermissionRule(rule.Rule)
+		if err != nil {
+			continue
+		}
+		parsed = append(parsed, permissionRule{action: rule.Action, tool: tool, pattern: pattern})
+	}
+	return parsed
+}
+
+// matches reports whether the rule applies to request. A rule without a
+// pattern matches every call of its tool. Allow rules only match when every
+// command and path of the call matches, deny and ask rules when any does, and
+// only match calls outside the workspace when their pattern is absolute.
+func (r permissionRule) matches(request PermissionRequest, workingDir, homeDir string) bool {
+	if r.tool != request.ToolName {
+		return false
+	}
+	all := r.action == config.PermissionAllow
+	if all && len(request.Outside) > 0 && !r.absolute() {
+		// Only rules naming paths outside the workspace allow access to them
+		return false
+	}
+	if r.pattern == "" {
+		return true
+	}
+	switch {
+	case request.Command != "":
+		commands, substitution := splitShellCommand(request.Command)
+		if all && substitution {
+			// The commands run by a substitution aren't known
+			return false
+		}
+		return matchEach(commands, all, func(command string) bool {
+			return commandMatches(r.pattern, command)
+		})
+	case len(request.Paths) > 0:
+		return matchEach(request.Paths, all, func(path string) bool {
+			return pathMatches(r.pattern, path, workingDir, homeDir)
+		})
+	}
+	return false
+}
+
+// absolute reports whether the rule's pattern is an absolute path or starts
+// with ~
+func (r permissionRule) absolute() bool {
+	return r.pattern == "~" || strings.HasPrefix(r.pattern, "~/") || filepath.IsAbs(r.pattern)
+}
+
+// GrantRule returns the rule a permission granted beyond a single call
+// covers: the command's first words for shell commands, otherwise the tool.
+// It is empty when no such rule would cover the call, as for calls outside
+// the workspace.
+func (r PermissionRequest) GrantRule() string {
+	if len(r.Outside) > 0 {
+		return ""
+	}
+	if r.Command == "" {
+		return r.ToolName
+	}
+
+	commands, substitution := splitShellCommand(r.Command)
+	if substitution || len(commands) == 0 {
+		return ""
+	}
+	words := strings.Fields(commands[0])
+	prefix := words[0]
+	if len(words) > 1 && subcommandPattern.MatchString(words[1]) {
+		prefix += " " + words[1]
+	}
+	return fmt.Sprintf("%s(%s:*)", r.ToolName, prefix)
+}
+
+// subcommandPattern matches words that are likely subcommands, like the
+// "test" of "go test", rather than flags or paths
+var subcommandPattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// matchEach reports whether all or any of items match
+func matchEach(items []string, all bool, match func(string) bool) bool {
+	if len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		if match(item) != all {
+			return !all
+		}
+	}
+	return all
+}
+
+// splitShellCommand splits a command line into the commands separated by
+// ;, &, &&, ||, | and newlines outside quotes. substitution reports whether
+// the line contains a command or process substitution.
+func splitShellCommand(line string) (commands []string, substitution bool) {
+	var current strings.Builder
+	flush := func() {
+		if command := strings.TrimSpace(current.String()); command != "" {
+			commands = append(commands, command)
+		}
+		current.Reset()
+	}
+
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case c == '\\' && i+1 < len(line):
+			current.WriteByte(c)
+			i++
+			c = line[i]
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else if c == '`' || (c == '$' && i+1 < len(line) && line[i+1] == '(') {
+				substitution = true
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '`' || (strings.IndexByte("$<>", c) >= 0 && i+1 < len(line) && line[i+1] == '('):
+			// $(...), <(...) and >(...)
+			substitution = true
+		case c == ';' || c == '\n' || c == '|':
+			flush()
+			if i+1 < len(line) && line[i+1] == c {
+				i++
+			}
+			continue
+		case c == '&':
+			// Redirections like 2>&1 and &> don't separate commands
+			redirect := (i > 0 && (line[i-1] == '>' || line[i-1] == '<')) || (i+1 < len(line) && line[i+1] == '>')
+			if !redirect {
+				flush()
+				if i+1 < len(line) && line[i+1] == '&' {
+					i++
+				}
+				continue
+			}
+		}
+		current.WriteByte(c)
+	}
+	flush()
+	return commands, substitution
+}
+
+// commandMatches matches a command against a pattern. A pattern ending in
+// ":*" matches commands starting with the words before it, any other
+// pattern is a glob where * matches any text.
+func commandMatches(pattern, command string) bool {
+	command = strings.Join(strings.Fields(command), " ")
+
+	if prefix, ok := strings.CutSuffix(pattern, ":*"); ok {
+		prefix = strings.Join(strings.Fields(prefix), " ")
+		return command == prefix || strings.HasPrefix(command, prefix+" ")
+	}
+
+	pattern = strings.Join(strings.Fields(pattern), " ")
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	re, err := regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+	return err == nil && re.MatchString(command)
+}
+
+// pathMatches matches a path against a glob where * and ? don't match a
+// separator and ** matches any number of directories. Patterns starting with
+// ~ are relative to the home directory and other relative patterns to the
+// working directory; they never match paths outside it.
+func pathMatches(pattern, path, workingDir, homeDir string) bool {
+	if pattern == "~" || strings.HasPrefix(pattern, "~/") {
+		if homeDir == "" {
+			return false
+		}
+		pattern = homeDir + pattern[1:]
+	}
+	if strings.HasPrefix(path, "~/") && homeDir != "" {
+		path = filepath.Join(homeDir, path[2:])
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDir, path)
+	}
+	path = filepath.Clean(path)
+
+	if !filepath.IsAbs(pattern) {
+		rel, err := filepath.Rel(workingDir, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return false
+		}
+		path = rel
+	}
+
+	re, err := regexp.Compile(globToRegexp(filepath.ToSlash(pattern)))
+	return err == nil && re.MatchString(filepath.ToSlash(path))
+}
+
+// globToRegexp converts a path glob to an anchored regular expression
+func globToRegexp(glob string) string {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		rest := glob[i:]
+		switch {
+		case strings.HasPrefix(rest, "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case rest == "/**":
+			re.WriteString("(/.*)?")
+			i += 2
+		case strings.HasPrefix(rest, "**"):
+			re.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			re.WriteString("[^/]*")
+		case glob[i] == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(glob[i])))
+		}
+	}
+	re.WriteString("$")
+	return re.String()
+}
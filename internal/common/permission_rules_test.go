@@ -0,0 +1,233 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/recrsn/coder/internal/config"
+)
+
+// recordingPermissionHandler grants every request and records that it was asked
+type recordingPermissionHandler struct {
+	asked bool
+}
+
+func (h *recordingPermissionHandler) RequestPermission(request PermissionRequest) PermissionResponse {
+	h.asked = true
+	return PermissionResponse{Granted: true}
+}
+
+func TestPermissionManager_Rules(t *testing.T) {
+	rules := []config.PermissionRule{
+		{Action: config.PermissionDeny, Rule: "shell(rm -rf *)"},
+		{Action: config.PermissionDeny, Rule: "shell(git push:*)"},
+		{Action: config.PermissionAllow, Rule: "shell(go test:*)"},
+		{Action: config.PermissionAllow, Rule: "shell(git status)"},
+		{Action: config.PermissionDeny, Rule: "read(~/.ssh/**)"},
+		{Action: config.PermissionAllow, Rule: "write(src/**)"},
+		{Action: config.PermissionAsk, Rule: "grep(/etc/**)"},
+		{Action: config.PermissionAllow, Rule: "sed"},
+	}
+
+	tests := []struct {
+		name    string
+		request PermissionRequest
+		// expected is "allow", "deny" or "ask" when the handler was consulted
+		expected string
+	}{
+		{"command prefix", PermissionRequest{ToolName: "shell", Command: "go test ./..."}, "allow"},
+		{"command prefix alone", PermissionRequest{ToolName: "shell", Command: "go test"}, "allow"},
+		{"prefix needs a word boundary", PermissionRequest{ToolName: "shell", Command: "go testify"}, "ask"},
+		{"extra whitespace", PermissionRequest{ToolName: "shell", Command: "  go   test  -v"}, "allow"},
+		{"exact command", PermissionRequest{ToolName: "shell", Command: "git status"}, "allow"},
+		{"exact command with arguments", PermissionRequest{ToolName: "shell", Command: "git status --short"}, "ask"},
+		{"denied command", PermissionRequest{ToolName: "shell", Command: "rm -rf build"}, "deny"},
+		{"denied command after allowed one", PermissionRequest{ToolName: "shell", Command: "go test ./... && rm -rf /"}, "deny"},
+		{"allowed commands chained", PermissionRequest{ToolName: "shell", Command: "go test ./a; go test ./b"}, "allow"},
+		{"allowed command piped into another", PermissionRequest{ToolName: "shell", Command: "go test ./... | sh"}, "ask"},
+		{"redirection is not a separator", PermissionRequest{ToolName: "shell", Command: "go test ./... 2>&1"}, "allow"},
+		{"separator inside quotes", PermissionRequest{ToolName: "shell", Command: "go test -run 'A|B'"}, "allow"},
+		{"command substitution", PermissionRequest{ToolName: "shell", Command: "go test $(curl example.com)"}, "ask"},
+		{"process substitution", PermissionRequest{ToolName: "shell", Command: "go test <(rm -rf ~)"}, "ask"},
+		{"output process substitution", PermissionRequest{ToolName: "shell", Command: "go test ./... >(sh)"}, "ask"},
+		{"substitution hiding a denied command", PermissionRequest{ToolName: "shell", Command: "echo $(git push --force)"}, "ask"},
+		{"denied command with a substitution", PermissionRequest{ToolName: "shell", Command: "git push $(git remote)"}, "deny"},
+		{"substitution of an auto-approved tool", PermissionRequest{ToolName: "read", Command: "cat <(ls)"}, "ask"},
+		{"denied home path", PermissionRequest{ToolName: "read", Paths: []string{"/home/me/.ssh/id_rsa"}}, "deny"},
+		{"denied tilde path", PermissionRequest{ToolName: "read", Paths: []string{"~/.ssh/config"}}, "deny"},
+		{"auto-approved without matching rule", PermissionRequest{ToolName: "read", Paths: []string{"main.go"}}, "allow"},
+		{"allowed relative path", PermissionRequest{ToolName: "write", Paths: []string{"src/pkg/file.go"}}, "allow"},
+		{"allowed absolute path", PermissionRequest{ToolName: "write", Paths: []string{"/work/project/src/file.go"}}, "allow"},
+		{"path outside pattern", PermissionRequest{ToolName: "write", Paths: []string{"main.go"}}, "ask"},
+		{"path escaping the working directory", PermissionRequest{ToolName: "write", Paths: []string{"src/../../other/src/file.go"}}, "ask"},
+		{"every path must be allowed", PermissionRequest{ToolName: "write", Paths: []string{"src/a.go", "b.go"}}, "ask"},
+		{"ask overrides auto-approval", PermissionRequest{ToolName: "grep", Paths: []string{"/etc/passwd"}}, "ask"},
+		{"tool without pattern", PermissionRequest{ToolName: "sed", Paths: []string{"anything"}}, "allow"},
+		{"pattern needs a subject", PermissionRequest{ToolName: "write"}, "ask"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &recordingPermissionHandler{}
+			manager := NewPermissionManager(config.PermissionConfig{
+				AutoApprove: map[string]bool{"read": true, "grep": true},
+				Rules:       rules,
+			}, handler)
+			manager.workingDir = "/work/project"
+			manager.workspace = &Workspace{roots: []string{"/work/project"}}
+			manager.homeDir = "/home/me"
+
+			response := manager.RequestPermission(tc.request)
+
+			actual := "deny"
+			switch {
+			case handler.asked:
+				actual = "ask"
+			case response.Granted:
+				actual = "allow"
+			}
+			if actual != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParsePermissionRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		tool    string
+		pattern string
+		wantErr bool
+	}{
+		{rule: "shell", tool: "shell"},
+		{rule: "shell(go test:*)", tool: "shell", pattern: "go test:*"},
+		{rule: "shell(echo (a))", tool: "shell", pattern: "echo (a)"},
+		{rule: " write( src/** ) ", tool: "write", pattern: "src/**"},
+		{rule: "", wantErr: true},
+		{rule: "(src/**)", wantErr: true},
+		{rule: "write(src/**", wantErr: true},
+		{rule: "write()", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.rule, func(t *testing.T) {
+			tool, pattern, err := config.ParsePermissionRule(tc.rule)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %v, got %v", tc.wantErr, err)
+			}
+			if tool != tc.tool || pattern != tc.pattern {
+				t.Errorf("Expected %q and %q, got %q and %q", tc.tool, tc.pattern, tool, pattern)
+			}
+		})
+	}
+}
+
+// scopedPermissionHandler grants every request with a scope and counts the requests
+type scopedPermissionHandler struct {
+	scope PermissionScope
+	asked int
+}
+
+func (h *scopedPermissionHandler) RequestPermission(request PermissionRequest) PermissionResponse {
+	h.asked++
+	return PermissionResponse{Granted: true, Scope: h.scope}
+}
+
+func TestPermissionManager_Grants(t *testing.T) {
+	tests := []struct {
+		name   string
+		scope  PermissionScope
+		first  PermissionRequest
+		second PermissionRequest
+		// asked is how often the handler is asked for both requests
+		asked int
+		saved string
+	}{
+		{
+			name:   "once",
+			scope:  ScopeOnce,
+			first:  PermissionRequest{ToolName: "write", Paths: []string{"a.go"}},
+			second: PermissionRequest{ToolName: "write", Paths: []string{"b.go"}},
+			asked:  2,
+		},
+		{
+			name:   "tool for the session",
+			scope:  ScopeSession,
+			first:  PermissionRequest{ToolName: "write", Paths: []string{"a.go"}},
+			second: PermissionRequest{ToolName: "write", Paths: []string{"b.go"}},
+			asked:  1,
+		},
+		{
+			name:   "command prefix for the session",
+			scope:  ScopeSession,
+			first:  PermissionRequest{ToolName: "shell", Command: "go test ./a"},
+			second: PermissionRequest{ToolName: "shell", Command: "go test -v ./b"},
+			asked:  1,
+		},
+		{
+			name:   "other command",
+			scope:  ScopeSession,
+			first:  PermissionRequest{ToolName: "shell", Command: "go test ./a"},
+			second: PermissionRequest{ToolName: "shell", Command: "go build ./a"},
+			asked:  2,
+		},
+		{
+			name:   "grant doesn't override deny rules",
+			scope:  ScopeSession,
+			first:  PermissionRequest{ToolName: "shell", Command: "rm -f a"},
+			second: PermissionRequest{ToolName: "shell", Command: "rm -rf b"},
+			asked:  1,
+		},
+		{
+			name:   "always",
+			scope:  ScopeAlways,
+			first:  PermissionRequest{ToolName: "shell", Command: "npm run build"},
+			second: PermissionRequest{ToolName: "shell", Command: "npm run test"},
+			asked:  1,
+			saved:  "shell(npm run:*)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &scopedPermissionHandler{scope: tc.scope}
+			manager := NewPermissionManager(config.PermissionConfig{
+				Rules: []config.PermissionRule{{Action: config.PermissionDeny, Rule: "shell(rm -rf *)"}},
+			}, handler)
+			var saved string
+			manager.SetSaveRule(func(rule config.PermissionRule) {
+				saved = rule.Rule
+			})
+
+			manager.RequestPermission(tc.first)
+			manager.RequestPermission(tc.second)
+
+			if handler.asked != tc.asked {
+				t.Errorf("Expected %d requests to the handler, got %d", tc.asked, handler.asked)
+			}
+			if saved != tc.saved {
+				t.Errorf("Expected saved rule %q, got %q", tc.saved, saved)
+			}
+		})
+	}
+}
+
+func TestPermissionRequest_GrantRule(t *testing.T) {
+	tests := []struct {
+		request  PermissionRequest
+		expected string
+	}{
+		{PermissionRequest{ToolName: "write", Paths: []string{"a.go"}}, "write"},
+		{PermissionRequest{ToolName: "shell", Command: "go test ./..."}, "shell(go test:*)"},
+		{PermissionRequest{ToolName: "shell", Command: "ls -la"}, "shell(ls:*)"},
+		{PermissionRequest{ToolName: "shell", Command: "cat main.go | head"}, "shell(cat:*)"},
+		{PermissionRequest{ToolName: "shell", Command: "echo $(whoami)"}, ""},
+		{PermissionRequest{ToolName: "shell", Command: "diff <(ls a) <(ls b)"}, ""},
+	}
+
+	for _, tc := range tests {
+		if actual := tc.request.GrantRule(); actual != tc.expected {
+			t.Errorf("GrantRule of %+v: expected %q, got %q", tc.request, tc.expected, actual)
+		}
+	}
+}
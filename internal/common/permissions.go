@@ -13,6 +13,18 @@ type PermissionRequest struct {
 
 	// Context provides detailed information about the permission request
 	Context string
+
+	// Command is the shell command the tool runs, if any
+	Command string
+
+	// Paths are the files and directories the tool accesses, if any
+	Paths []string
+
+	// ReadOnly requests don't change anything, only those are granted in plan mode
+	ReadOnly bool
+
+	// Outside lists the Paths outside the workspace, set by the PermissionManager
+	Outside []string
 }
 
 // PermissionResponse represents the response to a permission request
@@ -22,8 +34,24 @@ type PermissionResponse struct {
 
 	// AlternateAction contains alternative instructions if permission was denied
 	AlternateAction string
+
+	// Scope is how long a granted permission lasts
+	Scope PermissionScope
 }
 
+// PermissionScope is how long a granted permission lasts. Beyond the
+// requested call it covers the calls matching the request's GrantRule.
+type PermissionScope int
+
+const (
+	// ScopeOnce grants only the requested call
+	ScopeOnce PermissionScope = iota
+	// ScopeSession grants matching calls until the session ends
+	ScopeSession
+	// ScopeAlways grants matching calls and saves the grant to the config
+	ScopeAlways
+)
+
 // PermissionHandler defines the interface for handling permission requests
 type PermissionHandler interface {
 	// RequestPermission requests permission for an action
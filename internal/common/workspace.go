@@ -0,0 +1,125 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Workspace is the set of directories file tools may access without asking:
+// the project root and the directories the user added
+type Workspace struct {
+	mu    sync.RWMutex
+	roots []string
+}
+
+// NewWorkspace creates a workspace rooted at the git repository containing
+// dir, or at dir itself outside a repository
+func NewWorkspace(dir string) *Workspace {
+	root, err := ResolvePath(FindProjectRoot(dir), "")
+	if err != nil {
+		root = filepath.Clean(dir)
+	}
+	return &Workspace{roots: []string{root}}
+}
+
+// FindProjectRoot returns the closest directory containing .git, starting at
+// dir, or dir if there is none
+func FindProjectRoot(dir string) string {
+	for current := dir; ; {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return dir
+		}
+		current = parent
+	}
+}
+
+// AddDir adds an existing directory to the workspace and returns its resolved path
+func (w *Workspace) AddDir(dir string) (string, error) {
+	resolved, err := ResolvePath(dir, "")
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("adding directory: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.roots = append(w.roots, resolved)
+	return resolved, nil
+}
+
+// Roots returns the directories of the workspace
+func (w *Workspace) Roots() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return append([]string(nil), w.roots...)
+}
+
+// Contains reports whether a resolved path is inside one of the roots
+func (w *Workspace) Contains(path string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, root := range w.roots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolvePath makes path absolute, relative to workingDir or the current
+// directory if that is empty, and resolves symlinks. Paths that don't exist
+// yet are resolved up to their closest existing parent.
+func ResolvePath(path, workingDir string) (string, error) {
+	if strings.HasPrefix(path, "~/") || path == "~" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("resolving %s: %w", path, err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	if !filepath.IsAbs(path) {
+		if workingDir == "" {
+			dir, err := os.Getwd()
+			if err != nil {
+				return "", fmt.Errorf("resolving %s: %w", path, err)
+			}
+			workingDir = dir
+		}
+		path = filepath.Join(workingDir, path)
+	}
+	path = filepath.Clean(path)
+
+	// Resolve the closest existing parent and append the rest
+	var missing []string
+	for current := path; ; {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("resolving %s: %w", path, err)
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return path, nil
+		}
+		missing = append([]string{filepath.Base(current)}, missing...)
+		current = parent
+	}
+}
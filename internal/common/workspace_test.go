@@ -0,0 +1,82 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/recrsn/coder/internal/config"
+)
+
+func TestPermissionManager_Workspace(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := filepath.Join(base, "project")
+	outside := filepath.Join(base, "outside")
+	extra := filepath.Join(base, "extra")
+	for _, dir := range []string{filepath.Join(project, ".git"), filepath.Join(project, "src"), outside, extra} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(project, "src", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		request  PermissionRequest
+		addDir   string
+		expected string
+	}{
+		{"auto-approved inside", PermissionRequest{ToolName: "read", Paths: []string{"src/main.go"}}, "", "allow"},
+		{"new file inside", PermissionRequest{ToolName: "read", Paths: []string{"src/new/file.go"}}, "", "allow"},
+		{"project root from a subdirectory", PermissionRequest{ToolName: "read", Paths: []string{"../README.md"}}, "", "allow"},
+		{"outside", PermissionRequest{ToolName: "read", Paths: []string{outside + "/secret"}}, "", "ask"},
+		{"symlink to outside", PermissionRequest{ToolName: "read", Paths: []string{"link/secret"}}, "", "ask"},
+		{"dot dot to outside", PermissionRequest{ToolName: "read", Paths: []string{"../../outside/secret"}}, "", "ask"},
+		{"added directory", PermissionRequest{ToolName: "read", Paths: []string{extra + "/file"}}, extra, "allow"},
+		{"allow rule outside", PermissionRequest{ToolName: "ls", Paths: []string{outside}}, "", "allow"},
+		{"allow rule without pattern inside", PermissionRequest{ToolName: "grep", Paths: []string{"main.go"}}, "", "allow"},
+		{"allow rule without pattern outside", PermissionRequest{ToolName: "grep", Paths: []string{outside}}, "", "ask"},
+		{"relative allow rule outside", PermissionRequest{ToolName: "tree", Paths: []string{"../../outside"}}, "", "ask"},
+		{"accept edits outside", PermissionRequest{ToolName: "write", Paths: []string{"link/file"}}, "", "ask"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &recordingPermissionHandler{}
+			manager := NewPermissionManager(config.PermissionConfig{
+				AutoApprove: map[string]bool{"read": true},
+				Rules: []config.PermissionRule{
+					{Action: config.PermissionAllow, Rule: "ls(" + outside + ")"},
+					{Action: config.PermissionAllow, Rule: "grep"},
+					{Action: config.PermissionAllow, Rule: "tree(**)"},
+				},
+			}, handler)
+			manager.workingDir = filepath.Join(project, "src")
+			manager.workspace = NewWorkspace(manager.workingDir)
+			manager.SetMode(ModeAcceptEdits)
+			if tc.addDir != "" {
+				if _, err := manager.Workspace().AddDir(tc.addDir); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			response := manager.RequestPermission(tc.request)
+
+			actual := "deny"
+			switch {
+			case handler.asked:
+				actual = "ask"
+			case response.Granted:
+				actual = "allow"
+			}
+			if actual != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
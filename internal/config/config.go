@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/recrsn/coder/internal/llm"
 	"github.com/spf13/viper"
 )
 
@@ -13,14 +14,24 @@ type Config struct {
 	Provider    ProviderConfig   `mapstructure:"provider"`
 	UI          UIConfig         `mapstructure:"ui"`
 	Permissions PermissionConfig `mapstructure:"permissions"`
+	Usage       UsageConfig      `mapstructure:"usage"`
+	Context     ContextConfig    `mapstructure:"context"`
+	Tools       ToolsConfig      `mapstructure:"tools"`
 }
 
 // ProviderConfig holds provider-specific configuration
 type ProviderConfig struct {
+	// Type selects the API the provider speaks: "openai", "anthropic" or "local"
+	Type      string `mapstructure:"type"`
 	Endpoint  string `mapstructure:"endpoint"`
 	APIKey    string `mapstructure:"api_key"`
 	Model     string `mapstructure:"model"`
 	LiteModel string `mapstructure:"lite_model"`
+	Stream    bool   `mapstructure:"stream"`
+	// ToolCalling is how local models are given tools: "auto", "native" or "text"
+	ToolCalling string `mapstructure:"tool_calling"`
+	// MaxRetries is how often a request is retried after a transient failure
+	MaxRetries int `mapstructure:"max_retries"`
 }
 
 // UIConfig holds UI-specific configuration
@@ -60,9 +71,113 @@ func LoadConfig() (Config, error) {
 		return config, fmt.Errorf("unmarshaling config: %w", err)
 	}
 
+	// Only the first config file found is read above, but permission rules
+	// and the sandbox come from both
+	files, err := readConfigFiles(homeDir)
+	if err != nil {
+		return config, err
+	}
+	rules, err := loadPermissionRules(files)
+	if err != nil {
+		return config, err
+	}
+	config.Permissions.Rules = rules
+	sandbox, err := loadSandboxConfig(files)
+	if err != nil {
+		return config, err
+	}
+	config.Tools.Sandbox = sandbox
+
+	// The default endpoint is OpenAI's, so point other providers at their own API
+	if !viper.IsSet("provider.endpoint") {
+		switch config.Provider.Type {
+		case llm.ProviderAnthropic:
+			config.Provider.Endpoint = llm.DefaultAnthropicEndpoint
+		case llm.ProviderLocal:
+			config.Provider.Endpoint = llm.DefaultLocalEndpoint
+		}
+	}
+
 	return config, nil
 }
 
+// configFile is a config file read on its own
+type configFile struct {
+	path    string
+	project bool
+	viper   *viper.Viper
+}
+
+// readConfigFiles reads the user config and then the project config, those
+// of them that exist
+func readConfigFiles(homeDir string) ([]configFile, error) {
+	var files []configFile
+	if homeDir != "" {
+		files = append(files, configFile{path: filepath.Join(homeDir, ".coder.yaml")})
+	}
+	if projectPath, err := filepath.Abs(".coder.yaml"); err == nil && (len(files) == 0 || projectPath != files[0].path) {
+		files = append(files, configFile{path: projectPath, project: true})
+	}
+
+	existing := files[:0]
+	for _, file := range files {
+		if _, err := os.Stat(file.path); err != nil {
+			continue
+		}
+
+		file.viper = viper.New()
+		file.viper.SetConfigFile(file.path)
+		if err := file.viper.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", file.path, err)
+		}
+		existing = append(existing, file)
+	}
+	return existing, nil
+}
+
+// loadPermissionRules reads the permission rules of the user config followed
+// by those of the project config, so a project can't override a user's rules.
+// The allow rules of the project config are ignored, as a cloned repository
+// mustn't grant itself permissions.
+func loadPermissionRules(files []configFile) ([]PermissionRule, error) {
+	var rules []PermissionRule
+	for _, file := range files {
+		var fileRules []PermissionRule
+		if err := file.viper.UnmarshalKey("permissions.rules", &fileRules); err != nil {
+			return nil, fmt.Errorf("reading permission rules of %s: %w", file.path, err)
+		}
+		if err := validatePermissionRules(fileRules); err != nil {
+			return nil, fmt.Errorf("%s: %w", file.path, err)
+		}
+		for _, rule := range fileRules {
+			if file.project && rule.Action == PermissionAllow {
+				continue
+			}
+			rule.Project = file.project
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// loadSandboxConfig reads the sandbox settings of the user config and lets
+// the project config enable the sandbox and tighten them
+func loadSandboxConfig(files []configFile) (SandboxConfig, error) {
+	user := DefaultToolsConfig().Sandbox
+	for _, file := range files {
+		// Settings a file leaves out keep the user's value
+		sandbox := user
+		if err := file.viper.UnmarshalKey("tools.sandbox", &sandbox); err != nil {
+			return user, fmt.Errorf("reading sandbox settings of %s: %w", file.path, err)
+		}
+		if file.project {
+			return mergeSandboxConfigs(user, sandbox), nil
+		}
+		user = sandbox
+	}
+	return user, nil
+}
+
 // SaveConfig saves the configuration to file
 func SaveConfig(config Config) error {
 	homeDir, err := os.UserHomeDir()
@@ -75,22 +190,68 @@ func SaveConfig(config Config) error {
 	// Set config values
 	viper.SetConfigFile(configPath)
 
+	viper.Set("provider.type", config.Provider.Type)
 	viper.Set("provider.api_key", config.Provider.APIKey)
 	viper.Set("provider.model", config.Provider.Model)
 	viper.Set("provider.endpoint", config.Provider.Endpoint)
+	viper.Set("provider.stream", config.Provider.Stream)
+	viper.Set("provider.tool_calling", config.Provider.ToolCalling)
+	viper.Set("provider.max_retries", config.Provider.MaxRetries)
 
 	viper.Set("ui.color_enabled", config.UI.ColorEnabled)
 	viper.Set("ui.show_spinner", config.UI.ShowSpinner)
 	viper.Set("ui.use_bubble_tea", config.UI.UseBubbleTea)
 
+	viper.Set("usage.budget", config.Usage.Budget)
+
+	viper.Set("context.auto_compact", config.Context.AutoCompact)
+	viper.Set("context.compact_threshold", config.Context.CompactThreshold)
+	viper.Set("context.keep_turns", config.Context.KeepTurns)
+
+	viper.Set("tools.parallel_workers", config.Tools.ParallelWorkers)
+	for tool, timeout := range config.Tools.Timeouts {
+		viper.Set(fmt.Sprintf("tools.timeouts.%s", tool), timeout.String())
+	}
+
 	// Save permission settings
 	for tool, autoApprove := range config.Permissions.AutoApprove {
 		viper.Set(fmt.Sprintf("permissions.auto_approve.%s", tool), autoApprove)
 	}
-
 	return viper.WriteConfig()
 }
 
+// SavePermissionRule adds rule to the permission rules of the user config,
+// ~/.coder.yaml, leaving the rest of the file as it is
+func SavePermissionRule(rule PermissionRule) error {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("getting home dir: %w", err)
+	}
+	configPath := filepath.Join(homeDir, ".coder.yaml")
+
+	// Only this file is written, not the settings merged from others
+	file := viper.New()
+	file.SetConfigFile(configPath)
+	if _, err := os.Stat(configPath); err == nil {
+		if err := file.ReadInConfig(); err != nil {
+			return fmt.Errorf("reading %s: %w", configPath, err)
+		}
+	}
+
+	var rules []PermissionRule
+	if err := file.UnmarshalKey("permissions.rules", &rules); err != nil {
+		return fmt.Errorf("reading permission rules of %s: %w", configPath, err)
+	}
+	rules = append(rules, rule)
+
+	entries := make([]map[string]string, len(rules))
+	for i, rule := range rules {
+		entries[i] = map[string]string{"action": rule.Action, "rule": rule.Rule}
+	}
+	file.Set("permissions.rules", entries)
+	return file.WriteConfig()
+}
+
 // GetDataDir returns the data directory for the application
 func GetDataDir() (string, error) {
 	homeDir, err := os.UserHomeDir()
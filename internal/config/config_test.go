@@ -0,0 +1,96 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestSavePermissionRule(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configPath := filepath.Join(home, ".coder.yaml")
+	existing := "provider:\n  model: my-model\npermissions:\n  rules:\n    - action: deny\n      rule: shell(rm:*)\n"
+	if err := os.WriteFile(configPath, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SavePermissionRule(PermissionRule{Action: PermissionAllow, Rule: "shell(go test:*)"}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"model: my-model", "rule: shell(rm:*)", "rule: shell(go test:*)"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected %q in the saved config:\n%s", expected, content)
+		}
+	}
+	// Nothing but the file's own settings and the new rule is written
+	for _, unexpected := range []string{"endpoint", "auto_approve", "sandbox"} {
+		if strings.Contains(string(content), unexpected) {
+			t.Errorf("Expected no %q in the saved config:\n%s", unexpected, content)
+		}
+	}
+}
+
+func TestSavePermissionRule_NewFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	if err := SavePermissionRule(PermissionRule{Action: PermissionAllow, Rule: "write"}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(home, ".coder.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "rule: write") {
+		t.Errorf("Expected the rule in the new config:\n%s", content)
+	}
+}
+
+func TestLoadPermissionRules_ProjectAllowRulesIgnored(t *testing.T) {
+	dir := t.TempDir()
+	userPath := filepath.Join(dir, "user.yaml")
+	projectPath := filepath.Join(dir, "project.yaml")
+	user := "permissions:\n  rules:\n    - action: allow\n      rule: shell(go test:*)\n"
+	project := "permissions:\n  rules:\n    - action: allow\n      rule: shell\n" +
+		"    - action: deny\n      rule: shell(rm:*)\n    - action: ask\n      rule: write\n"
+	if err := os.WriteFile(userPath, []byte(user), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(projectPath, []byte(project), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var files []configFile
+	for _, file := range []configFile{{path: userPath}, {path: projectPath, project: true}} {
+		file.viper = viper.New()
+		file.viper.SetConfigFile(file.path)
+		if err := file.viper.ReadInConfig(); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	rules, err := loadPermissionRules(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []PermissionRule{
+		{Action: PermissionAllow, Rule: "shell(go test:*)"},
+		{Action: PermissionDeny, Rule: "shell(rm:*)", Project: true},
+		{Action: PermissionAsk, Rule: "write", Project: true},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, rules)
+	}
+}
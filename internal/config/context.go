@@ -0,0 +1,60 @@
+package config
+
+import "strings"
+
+// ContextConfig holds configuration for managing the model's context window
+type ContextConfig struct {
+	// Windows lists the context window of models in tokens, matched by model name prefix
+	Windows []ModelContextWindow `mapstructure:"windows"`
+
+	// DefaultWindow is the context window of models missing from Windows
+	DefaultWindow int `mapstructure:"default_window"`
+
+	// AutoCompact summarizes older messages once the conversation fills
+	// CompactThreshold of the context window
+	AutoCompact      bool    `mapstructure:"auto_compact"`
+	CompactThreshold float64 `mapstructure:"compact_threshold"`
+
+	// KeepTurns is the number of recent turns kept verbatim when compacting
+	KeepTurns int `mapstructure:"keep_turns"`
+}
+
+// ModelContextWindow is the context window of a model in tokens
+type ModelContextWindow struct {
+	Model  string `mapstructure:"model"`
+	Tokens int    `mapstructure:"tokens"`
+}
+
+// WindowFor returns the context window of model. Like pricing, a model
+// matches the longest configured name that is a prefix of its own.
+func (c ContextConfig) WindowFor(model string) int {
+	model = strings.ToLower(model)
+
+	best := ModelContextWindow{Tokens: c.DefaultWindow}
+	for _, window := range c.Windows {
+		if strings.HasPrefix(model, strings.ToLower(window.Model)) && len(window.Model) > len(best.Model) {
+			best = window
+		}
+	}
+	return best.Tokens
+}
+
+// DefaultContextConfig returns the default context configuration
+func DefaultContextConfig() ContextConfig {
+	return ContextConfig{
+		Windows: []ModelContextWindow{
+			{Model: "gpt-4o", Tokens: 128000},
+			{Model: "gpt-4.1", Tokens: 1047576},
+			{Model: "o3-mini", Tokens: 200000},
+			{Model: "o4-mini", Tokens: 200000},
+			{Model: "claude", Tokens: 200000},
+			{Model: "llama3", Tokens: 8192},
+			{Model: "llama3.1", Tokens: 131072},
+			{Model: "qwen2.5-coder", Tokens: 32768},
+		},
+		DefaultWindow:    32768,
+		AutoCompact:      true,
+		CompactThreshold: 0.8,
+		KeepTurns:        2,
+	}
+}
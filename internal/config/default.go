@@ -1,12 +1,18 @@
 package config
 
+import "github.com/recrsn/coder/internal/llm"
+
 // DefaultConfig returns a default configuration
 func DefaultConfig() Config {
 	return Config{
 		Provider: ProviderConfig{
-			Endpoint: "https://api.openai.com/v1",
-			APIKey:   "",
-			Model:    "gpt-4o",
+			Type:        llm.ProviderOpenAI,
+			Endpoint:    "https://api.openai.com/v1",
+			APIKey:      "",
+			Model:       "gpt-4o",
+			Stream:      true,
+			ToolCalling: "auto",
+			MaxRetries:  3,
 		},
 		UI: UIConfig{
 			ColorEnabled: true,
@@ -14,5 +20,8 @@ func DefaultConfig() Config {
 			UseBubbleTea: true,
 		},
 		Permissions: DefaultPermissionConfig(),
+		Usage:       DefaultUsageConfig(),
+		Context:     DefaultContextConfig(),
+		Tools:       DefaultToolsConfig(),
 	}
 }
@@ -1,9 +1,75 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+)
+
+// Actions of a permission rule
+const (
+	PermissionAllow = "allow"
+	PermissionDeny  = "deny"
+	PermissionAsk   = "ask"
+)
+
 // PermissionConfig holds configuration for permission handling
 type PermissionConfig struct {
 	// AutoApprove automatically approves certain types of tools without asking
 	AutoApprove map[string]bool `mapstructure:"auto_approve"`
+	// Rules are checked in order before AutoApprove, the first rule that
+	// matches a tool call decides it
+	Rules []PermissionRule `mapstructure:"rules"`
+}
+
+// PermissionRule allows, denies or asks about the tool calls matching Rule.
+// Rule is a tool name, optionally followed by a pattern in parentheses that
+// is matched against the command or paths of the call, e.g.
+// "shell(go test:*)" or "write(src/**)".
+type PermissionRule struct {
+	Action string `mapstructure:"action"`
+	Rule   string `mapstructure:"rule"`
+	// Project rules come from the project config and aren't saved to the user's
+	Project bool `mapstructure:"-"`
+}
+
+// ParsePermissionRule splits a rule into the tool name and the pattern. The
+// pattern is empty when the rule applies to every call of the tool.
+func ParsePermissionRule(rule string) (tool string, pattern string, err error) {
+	rule = strings.TrimSpace(rule)
+	tool, pattern, hasPattern := strings.Cut(rule, "(")
+	tool = strings.TrimSpace(tool)
+	if tool == "" {
+		return "", "", fmt.Errorf("invalid permission rule %q: missing tool name", rule)
+	}
+	if strings.ContainsAny(tool, " )") {
+		return "", "", fmt.Errorf("invalid permission rule %q: invalid tool name", rule)
+	}
+	if !hasPattern {
+		return tool, "", nil
+	}
+	if !strings.HasSuffix(pattern, ")") {
+		return "", "", fmt.Errorf("invalid permission rule %q: missing closing parenthesis", rule)
+	}
+	pattern = strings.TrimSpace(strings.TrimSuffix(pattern, ")"))
+	if pattern == "" {
+		return "", "", fmt.Errorf("invalid permission rule %q: empty pattern", rule)
+	}
+	return tool, pattern, nil
+}
+
+// validatePermissionRules checks the action and syntax of every rule
+func validatePermissionRules(rules []PermissionRule) error {
+	for _, rule := range rules {
+		switch rule.Action {
+		case PermissionAllow, PermissionDeny, PermissionAsk:
+		default:
+			return fmt.Errorf("invalid action %q of permission rule %q, expected allow, deny or ask", rule.Action, rule.Rule)
+		}
+		if _, _, err := ParsePermissionRule(rule.Rule); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // DefaultPermissionConfig returns the default permission configuration
@@ -21,6 +87,8 @@ func DefaultPermissionConfig() PermissionConfig {
 			"references":    true,
 			"agent":         true,
 			"callHierarchy": true,
+			"job_output":    true,
+			"jobs":          true,
 		},
 	}
 }
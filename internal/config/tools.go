@@ -0,0 +1,66 @@
+package config
+
+import "time"
+
+// ToolsConfig holds configuration for running tools
+type ToolsConfig struct {
+	// ParallelWorkers is how many read-only tool calls of one turn run at the same time
+	ParallelWorkers int `mapstructure:"parallel_workers"`
+
+	// Timeouts overrides the default timeout of tools by name, e.g. "shell: 30m"
+	Timeouts map[string]time.Duration `mapstructure:"timeouts"`
+
+	// Sandbox confines shell commands
+	Sandbox SandboxConfig `mapstructure:"sandbox"`
+}
+
+// SandboxConfig configures the sandbox shell commands run in on Linux. Only
+// the workspace and WritableDirs are writable in it.
+type SandboxConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Network allows sandboxed commands to access the network
+	Network bool `mapstructure:"network"`
+	// WritableDirs are writable besides the workspace, e.g. build caches
+	WritableDirs []string `mapstructure:"writable_dirs"`
+	// CPUSeconds, MemoryMB and MaxProcesses limit each command, 0 for no
+	// limit. MaxProcesses is RLIMIT_NPROC, which counts every process of the
+	// user, not only those of the sandbox.
+	CPUSeconds   int `mapstructure:"cpu_seconds"`
+	MemoryMB     int `mapstructure:"memory_mb"`
+	MaxProcesses int `mapstructure:"max_processes"`
+}
+
+// DefaultToolsConfig returns the default tools configuration
+func DefaultToolsConfig() ToolsConfig {
+	return ToolsConfig{
+		ParallelWorkers: 4,
+		Sandbox: SandboxConfig{
+			CPUSeconds:   600,
+			MemoryMB:     4096,
+			MaxProcesses: 1024,
+		},
+	}
+}
+
+// mergeSandboxConfigs combines the sandbox settings of the user and the
+// project config. Either can enable the sandbox and tighten its limits, but
+// neither can loosen what the other set.
+func mergeSandboxConfigs(user, project SandboxConfig) SandboxConfig {
+	merged := SandboxConfig{
+		Enabled:      user.Enabled || project.Enabled,
+		Network:      user.Network && project.Network,
+		WritableDirs: user.WritableDirs,
+		CPUSeconds:   minLimit(user.CPUSeconds, project.CPUSeconds),
+		MemoryMB:     minLimit(user.MemoryMB, project.MemoryMB),
+		MaxProcesses: minLimit(user.MaxProcesses, project.MaxProcesses),
+	}
+	return merged
+}
+
+// minLimit returns the lower of two limits where 0 means no limit
+func minLimit(a, b int) int {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
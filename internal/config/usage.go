@@ -0,0 +1,37 @@
+package config
+
+// UsageConfig holds configuration for token usage and cost tracking
+type UsageConfig struct {
+	// Pricing lists the price of models, matched by model name prefix
+	Pricing []ModelPricing `mapstructure:"pricing"`
+
+	// Budget stops the agent once the session has cost this many dollars, 0 disables it
+	Budget float64 `mapstructure:"budget"`
+}
+
+// ModelPricing is the price of a model in dollars per million tokens
+type ModelPricing struct {
+	Model  string  `mapstructure:"model"`
+	Input  float64 `mapstructure:"input"`
+	Output float64 `mapstructure:"output"`
+}
+
+// DefaultUsageConfig returns the default usage configuration
+func DefaultUsageConfig() UsageConfig {
+	return UsageConfig{
+		Pricing: []ModelPricing{
+			{Model: "gpt-4o", Input: 2.50, Output: 10.00},
+			{Model: "gpt-4o-mini", Input: 0.15, Output: 0.60},
+			{Model: "gpt-4.1", Input: 2.00, Output: 8.00},
+			{Model: "gpt-4.1-mini", Input: 0.40, Output: 1.60},
+			{Model: "gpt-4.1-nano", Input: 0.10, Output: 0.40},
+			{Model: "o3-mini", Input: 1.10, Output: 4.40},
+			{Model: "o4-mini", Input: 1.10, Output: 4.40},
+			{Model: "claude-3-5-haiku", Input: 0.80, Output: 4.00},
+			{Model: "claude-3-5-sonnet", Input: 3.00, Output: 15.00},
+			{Model: "claude-3-7-sonnet", Input: 3.00, Output: 15.00},
+			{Model: "claude-sonnet-4", Input: 3.00, Output: 15.00},
+			{Model: "claude-opus-4", Input: 15.00, Output: 75.00},
+		},
+	}
+}
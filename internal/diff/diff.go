@@ -0,0 +1,179 @@
+// Package diff compares texts line by line and formats the result as a
+// unified diff
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// contextLines is how many unchanged lines surround the changes of a hunk
+const contextLines = 3
+
+// Line is a line of a diff
+type Line struct {
+	// Kind is ' ' for an unchanged line, '-' for a removed line or '+' for
+	// an added line
+	Kind byte
+	Text string
+	// NoNewline is set on the last line of a text that doesn't end with a
+	// newline
+	NoNewline bool
+}
+
+// Lines compares oldText to newText line by line
+func Lines(oldText, newText string) []Line {
+	oldLines, newLines := splitLines(oldText), splitLines(newText)
+
+	// Diff the lines as runes, one per distinct line
+	index := make(map[string]rune)
+	var texts []string
+	toRunes := func(lines []string) []rune {
+		runes := make([]rune, len(lines))
+		for i, line := range lines {
+			r, ok := index[line]
+			if !ok {
+				r = lineRune(len(texts))
+				index[line] = r
+				texts = append(texts, line)
+			}
+			runes[i] = r
+		}
+		return runes
+	}
+	oldRunes, newRunes := toRunes(oldLines), toRunes(newLines)
+
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = 0
+	var lines []Line
+	for _, d := range dmp.DiffMainRunes(oldRunes, newRunes, false) {
+		kind := byte(' ')
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			kind = '-'
+		case diffmatchpatch.DiffInsert:
+			kind = '+'
+		}
+		for _, r := range d.Text {
+			text := texts[runeIndex(r)]
+			line := Line{Kind: kind, Text: strings.TrimSuffix(text, "\n"), NoNewline: !strings.HasSuffix(text, "\n")}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// splitLines splits text after each newline, keeping the newlines
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineRune returns the rune standing for the line at index i, skipping the
+// surrogates, which aren't valid runes
+func lineRune(i int) rune {
+	r := rune(i + 1)
+	if r >= 0xD800 {
+		r += 0x800
+	}
+	return r
+}
+
+func runeIndex(r rune) int {
+	if r >= 0xD800 {
+		r -= 0x800
+	}
+	return int(r) - 1
+}
+
+// Stat returns how many lines were added and removed between oldText and
+// newText
+func Stat(oldText, newText string) (added, removed int) {
+	for _, line := range Lines(oldText, newText) {
+		switch line.Kind {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// Unified returns the unified diff from oldText to newText with the file
+// names oldName and newName, or an empty string if the texts are the same
+func Unified(oldName, newName, oldText, newText string) string {
+	lines := Lines(oldText, newText)
+
+	// The line numbers in both texts before each line
+	oldNumbers, newNumbers := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	for i, line := range lines {
+		oldNumbers[i+1], newNumbers[i+1] = oldNumbers[i], newNumbers[i]
+		if line.Kind != '+' {
+			oldNumbers[i+1]++
+		}
+		if line.Kind != '-' {
+			newNumbers[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(lines); {
+		for i < len(lines) && lines[i].Kind == ' ' {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+
+		// Extend the hunk over changes separated by little enough context
+		start, end := max(i-contextLines, 0), i
+		for {
+			for end < len(lines) && lines[end].Kind != ' ' {
+				end++
+			}
+			next := end
+			for next < len(lines) && lines[next].Kind == ' ' {
+				next++
+			}
+			if next == len(lines) || next-end > 2*contextLines {
+				end = min(end+contextLines, next)
+				break
+			}
+			end = next
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(oldNumbers[start], oldNumbers[end]), hunkRange(newNumbers[start], newNumbers[end]))
+		for _, line := range lines[start:end] {
+			out.WriteByte(line.Kind)
+			out.WriteString(line.Text)
+			out.WriteByte('\n')
+			if line.NoNewline {
+				out.WriteString("\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// hunkRange formats the lines after before up to end as in a hunk header
+func hunkRange(before, end int) string {
+	count := end - before
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
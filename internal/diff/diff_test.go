@@ -0,0 +1,82 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	numbers := func(from, to int, changed map[int]string) string {
+		var text strings.Builder
+		for i := from; i <= to; i++ {
+			if line, ok := changed[i]; ok {
+				text.WriteString(line + "\n")
+			} else {
+				text.WriteString(strings.Repeat("x", i) + "\n")
+			}
+		}
+		return text.String()
+	}
+
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+	}{
+		{
+			name:     "same",
+			old:      "a\nb\n",
+			new:      "a\nb\n",
+			expected: "",
+		},
+		{
+			name:     "change in the middle",
+			old:      numbers(1, 10, nil),
+			new:      numbers(1, 10, map[int]string{5: "five"}),
+			expected: "--- a/f\n+++ b/f\n@@ -2,7 +2,7 @@\n xx\n xxx\n xxxx\n-xxxxx\n+five\n xxxxxx\n xxxxxxx\n xxxxxxxx\n",
+		},
+		{
+			name: "distant changes make two hunks",
+			old:  numbers(1, 20, nil),
+			new:  numbers(1, 20, map[int]string{2: "two", 18: "eighteen"}),
+			expected: "--- a/f\n+++ b/f\n@@ -1,5 +1,5 @@\n x\n-xx\n+two\n xxx\n xxxx\n xxxxx\n" +
+				"@@ -15,6 +15,6 @@\n " + strings.Repeat("x", 15) + "\n " + strings.Repeat("x", 16) + "\n " +
+				strings.Repeat("x", 17) + "\n-" + strings.Repeat("x", 18) + "\n+eighteen\n " + strings.Repeat("x", 19) +
+				"\n " + strings.Repeat("x", 20) + "\n",
+		},
+		{
+			name:     "new file",
+			old:      "",
+			new:      "a\nb\n",
+			expected: "--- a/f\n+++ b/f\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:     "deleted lines",
+			old:      "a\nb\nc\n",
+			new:      "a\n",
+			expected: "--- a/f\n+++ b/f\n@@ -1,3 +1 @@\n a\n-b\n-c\n",
+		},
+		{
+			name:     "missing newline",
+			old:      "a\nb",
+			new:      "a\nb\n",
+			expected: "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("a/f", "b/f", tt.old, tt.new); got != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestStat(t *testing.T) {
+	added, removed := Stat("a\nb\nc\n", "a\nB\nc\nd\n")
+	if added != 2 || removed != 1 {
+		t.Errorf("expected +2/-1, got +%d/-%d", added, removed)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 )
 
 type ModelConfig struct {
@@ -17,15 +18,31 @@ type Agent struct {
 	tools            []Tool
 	Messages         []Message
 	config           ModelConfig
-	client           *Client
+	client           Provider
 	toolCallCallback func(ctx context.Context, toolName string, args map[string]any) (string, error)
 	messageCallback  func(message string)
+	streamCallback   func(delta string)
+	beforeRequest    func(ctx context.Context) error
+	usage            *UsageTracker
+
+	// readOnlyTool reports whether calls of a tool may run concurrently, on
+	// up to toolWorkers goroutines
+	readOnlyTool func(toolName string) bool
+	toolWorkers  int
+
+	// maxTurns limits the requests of one Run, 0 for no limit
+	maxTurns int
+
+	// Prompt and completion tokens reported for the last request, and the
+	// number of messages it was sent with
+	lastUsage    Usage
+	lastMessages int
 }
 
 func NewAgent(name string,
 	systemPrompt string,
 	tools []Tool, config ModelConfig,
-	client *Client,
+	client Provider,
 	toolCallCallback func(ctx context.Context, toolName string, args map[string]any) (string, error),
 	messageCallback func(message string),
 ) *Agent {
@@ -48,6 +65,40 @@ func NewAgent(name string,
 	}
 }
 
+// SetStreamCallback enables streaming responses. The callback receives the
+// assistant's text as it arrives; the complete message is still delivered to
+// the message callback once the response has finished.
+func (a *Agent) SetStreamCallback(callback func(delta string)) {
+	a.streamCallback = callback
+}
+
+// SetUsageTracker records the token usage of every request the agent makes.
+// Run stops once the tracker's budget is exceeded.
+func (a *Agent) SetUsageTracker(usage *UsageTracker) {
+	a.usage = usage
+}
+
+// SetParallelTools lets the agent run consecutive calls of read-only tools
+// concurrently on up to workers goroutines. The tool call callback must then
+// be safe for concurrent use by read-only tools. Other tools always run one
+// at a time.
+func (a *Agent) SetParallelTools(readOnly func(toolName string) bool, workers int) {
+	a.readOnlyTool = readOnly
+	a.toolWorkers = workers
+}
+
+// SetMaxTurns limits how many requests a single Run may make. Run fails
+// once the limit is reached without a final answer.
+func (a *Agent) SetMaxTurns(maxTurns int) {
+	a.maxTurns = maxTurns
+}
+
+// SetBeforeRequestCallback sets a callback that runs before every request
+// the agent makes, for example to compact the conversation. An error stops Run.
+func (a *Agent) SetBeforeRequestCallback(callback func(ctx context.Context) error) {
+	a.beforeRequest = callback
+}
+
 func (a *Agent) ClearContext() {
 	a.Messages = []Message{
 		{
@@ -55,6 +106,35 @@ func (a *Agent) ClearContext() {
 			Content: a.systemPrompt,
 		},
 	}
+	a.lastUsage = Usage{}
+}
+
+// ContextTokens estimates the number of tokens the next request will send.
+// It starts from the usage reported for the last request and estimates only
+// the messages added since.
+func (a *Agent) ContextTokens() int {
+	if a.lastUsage.PromptTokens == 0 || a.lastMessages >= len(a.Messages) {
+		return EstimateTokens(a.Messages)
+	}
+
+	// The first message after the last request is its reply
+	return a.lastUsage.PromptTokens + a.lastUsage.CompletionTokens +
+		EstimateTokens(a.Messages[a.lastMessages+1:])
+}
+
+// RestoreMessages replaces the conversation after the system prompt with
+// messages, e.g. when resuming a saved session
+func (a *Agent) RestoreMessages(messages []Message) {
+	a.Messages = append([]Message{a.Messages[0]}, messages...)
+	a.lastUsage = Usage{}
+}
+
+// CompactMessages replaces the messages between the system prompt and
+// Messages[start] with summary
+func (a *Agent) CompactMessages(start int, summary Message) {
+	messages := []Message{a.Messages[0], summary}
+	a.Messages = append(messages, a.Messages[start:]...)
+	a.lastUsage = Usage{}
 }
 
 func (a *Agent) AddMessage(role string, content string) {
@@ -66,7 +146,7 @@ func (a *Agent) AddMessage(role string, content string) {
 
 // Run executes the agent's logic, executes the tools, and returns the final message
 func (a *Agent) Run(ctx context.Context) (Message, error) {
-	for {
+	for turn := 1; ; turn++ {
 		select {
 		case <-ctx.Done():
 			return Message{}, fmt.Errorf("operation interrupted")
@@ -74,6 +154,22 @@ func (a *Agent) Run(ctx context.Context) (Message, error) {
 			// Continue processing
 		}
 
+		if a.maxTurns > 0 && turn > a.maxTurns {
+			return Message{}, fmt.Errorf("reached the maximum of %d turns", a.maxTurns)
+		}
+
+		if a.usage != nil {
+			if err := a.usage.CheckBudget(); err != nil {
+				return Message{}, err
+			}
+		}
+
+		if a.beforeRequest != nil {
+			if err := a.beforeRequest(ctx); err != nil {
+				return Message{}, err
+			}
+		}
+
 		// Create chat completion request with tools
 		req := ChatCompletionRequest{
 			Model:       a.config.Model,
@@ -82,7 +178,13 @@ func (a *Agent) Run(ctx context.Context) (Message, error) {
 			Tools:       a.tools,
 		}
 
-		response, err := a.client.CreateChatCompletion(ctx, req)
+		var response *ChatCompletionResponse
+		var err error
+		if a.streamCallback != nil {
+			response, err = a.client.CreateChatCompletionStream(ctx, req, a.streamCallback)
+		} else {
+			response, err = a.client.CreateChatCompletion(ctx, req)
+		}
 
 		if err != nil {
 			// Check if the error was due to context cancellation
@@ -92,6 +194,12 @@ func (a *Agent) Run(ctx context.Context) (Message, error) {
 			return Message{}, fmt.Errorf("calling API: %w", err)
 		}
 
+		if a.usage != nil {
+			a.usage.Record(a.Name, a.config.Model, response.Usage)
+		}
+		a.lastUsage = response.Usage
+		a.lastMessages = len(req.Messages)
+
 		if len(response.Choices) == 0 {
 			return Message{}, fmt.Errorf("no response choices")
 		}
@@ -120,13 +228,14 @@ func (a *Agent) Run(ctx context.Context) (Message, error) {
 	}
 }
 
-// handleToolCalls processes tool calls from the LLM
+// handleToolCalls processes tool calls from the LLM. Consecutive calls of
+// read-only tools run concurrently; the results are added in call order.
 func (a *Agent) handleToolCalls(ctx context.Context, toolCalls []ToolCall) error {
 	if a.toolCallCallback == nil {
 		return fmt.Errorf("tool call callback not set")
 	}
 
-	for _, toolCall := range toolCalls {
+	for i := 0; i < len(toolCalls); {
 		// Check if the context has been cancelled
 		select {
 		case <-ctx.Done():
@@ -135,36 +244,108 @@ func (a *Agent) handleToolCalls(ctx context.Context, toolCalls []ToolCall) error
 			// Continue processing
 		}
 
-		toolName := toolCall.Function.Name
-		toolArgs := toolCall.Function.Arguments
-
-		// Parse tool arguments
-		var args map[string]any
-		err := json.Unmarshal([]byte(toolArgs), &args)
+		end := i + 1
+		if a.isReadOnly(toolCalls[i]) {
+			for end < len(toolCalls) && a.isReadOnly(toolCalls[end]) {
+				end++
+			}
+		}
 
+		results, err := a.runToolCalls(ctx, toolCalls[i:end])
 		if err != nil {
-			// Add tool response to Messages
-			toolResponse := fmt.Sprintf("Error parsing arguments for %a: %a", toolName, err.Error())
+			return err
+		}
+
+		for j, result := range results {
 			a.Messages = append(a.Messages, Message{
 				Role:       "tool",
-				Content:    toolResponse,
-				ToolCallID: toolCall.ID,
+				Content:    result,
+				ToolCallID: toolCalls[i+j].ID,
 			})
-			continue
 		}
+		i = end
+	}
 
-		result, err := a.toolCallCallback(ctx, toolName, args)
+	return nil
+}
+
+// isReadOnly reports whether toolCall may run alongside other calls
+func (a *Agent) isReadOnly(toolCall ToolCall) bool {
+	return a.readOnlyTool != nil && a.toolWorkers > 1 && a.readOnlyTool(toolCall.Function.Name)
+}
+
+// runToolCalls runs toolCalls on a bounded pool of workers and returns their
+// results in order. The first failure cancels the calls still running.
+func (a *Agent) runToolCalls(ctx context.Context, toolCalls []ToolCall) ([]string, error) {
+	results := make([]string, len(toolCalls))
+
+	if len(toolCalls) == 1 {
+		result, err := a.runToolCall(ctx, toolCalls[0])
 		if err != nil {
-			return fmt.Errorf("tool call failed: %w", err)
+			return nil, fmt.Errorf("tool call failed: %w", err)
 		}
-		a.Messages = append(a.Messages, Message{
-			Role:       "tool",
-			Content:    result,
-			ToolCallID: toolCall.ID,
-		})
+		results[0] = result
+		return results, nil
 	}
 
-	return nil
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var failOnce sync.Once
+	var failure error
+
+	for w := 0; w < min(a.toolWorkers, len(toolCalls)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				result, err := a.runToolCall(workerCtx, toolCalls[i])
+				if err != nil {
+					failOnce.Do(func() {
+						failure = err
+						cancel()
+					})
+					continue
+				}
+				results[i] = result
+			}
+		}()
+	}
+
+feed:
+	for i := range toolCalls {
+		select {
+		case jobs <- i:
+		case <-workerCtx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("tool execution interrupted")
+	}
+	if failure != nil {
+		return nil, fmt.Errorf("tool call failed: %w", failure)
+	}
+	return results, nil
+}
+
+// runToolCall parses the arguments of toolCall and runs it through the tool
+// call callback
+func (a *Agent) runToolCall(ctx context.Context, toolCall ToolCall) (string, error) {
+	toolName := toolCall.Function.Name
+
+	var args map[string]any
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+		// Let the model correct its arguments
+		return fmt.Sprintf("Error parsing arguments for %s: %s", toolName, err.Error()), nil
+	}
+
+	return a.toolCallCallback(ctx, toolName, args)
 }
 
 func (a *Agent) Clone() *Agent {
@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// scriptedProvider replies with the given responses in turn
+type scriptedProvider struct {
+	responses []*ChatCompletionResponse
+	requests  int
+}
+
+func (p *scriptedProvider) CreateChatCompletion(_ context.Context, _ ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	resp := p.responses[p.requests]
+	p.requests++
+	return resp, nil
+}
+
+func (p *scriptedProvider) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest, _ func(string)) (*ChatCompletionResponse, error) {
+	return p.CreateChatCompletion(ctx, req)
+}
+
+func toolCallResponse(names ...string) *ChatCompletionResponse {
+	message := Message{Role: "assistant"}
+	for i, name := range names {
+		message.ToolCalls = append(message.ToolCalls, ToolCall{
+			ID:       fmt.Sprintf("call_%d", i),
+			Type:     "function",
+			Function: FunctionCall{Name: name, Arguments: fmt.Sprintf(`{"n": %d}`, i)},
+		})
+	}
+	return &ChatCompletionResponse{Choices: []ChatCompletionChoice{{Message: message, FinishReason: "tool_calls"}}}
+}
+
+func stopResponse() *ChatCompletionResponse {
+	return &ChatCompletionResponse{Choices: []ChatCompletionChoice{{
+		Message:      Message{Role: "assistant", Content: "done"},
+		FinishReason: "stop",
+	}}}
+}
+
+func isRead(toolName string) bool {
+	return toolName == "read"
+}
+
+func TestAgentRunsReadOnlyToolsInParallel(t *testing.T) {
+	provider := &scriptedProvider{responses: []*ChatCompletionResponse{
+		toolCallResponse("read", "read", "read", "write", "read"),
+		stopResponse(),
+	}}
+
+	// The first three reads only finish once all of them are running
+	var started sync.WaitGroup
+	started.Add(3)
+	var running, maxRunning atomic.Int32
+
+	agent := NewAgent("Coder", "prompt", nil, ModelConfig{}, provider,
+		func(ctx context.Context, toolName string, args map[string]any) (string, error) {
+			n := int(args["n"].(float64))
+
+			current := running.Add(1)
+			defer running.Add(-1)
+			for {
+				highest := maxRunning.Load()
+				if current <= highest || maxRunning.CompareAndSwap(highest, current) {
+					break
+				}
+			}
+
+			if n < 3 {
+				started.Done()
+				done := make(chan struct{})
+				go func() {
+					started.Wait()
+					close(done)
+				}()
+				select {
+				case <-done:
+				case <-time.After(5 * time.Second):
+					return "", fmt.Errorf("read %d did not run concurrently", n)
+				}
+				// Finish in reverse order
+				time.Sleep(time.Duration(3-n) * 10 * time.Millisecond)
+			}
+			return fmt.Sprintf("%s %d", toolName, n), nil
+		}, nil)
+	agent.SetParallelTools(isRead, 3)
+	agent.AddMessage("user", "go")
+
+	if _, err := agent.Run(context.Background()); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	var results []string
+	for _, msg := range agent.Messages {
+		if msg.Role == "tool" {
+			results = append(results, msg.ToolCallID+"="+msg.Content)
+		}
+	}
+	want := "call_0=read 0,call_1=read 1,call_2=read 2,call_3=write 3,call_4=read 4"
+	if got := strings.Join(results, ","); got != want {
+		t.Errorf("unexpected results:\n got %s\nwant %s", got, want)
+	}
+	if got := maxRunning.Load(); got != 3 {
+		t.Errorf("expected 3 tool calls to run at once, got %d", got)
+	}
+}
+
+func TestAgentCancelsParallelTools(t *testing.T) {
+	provider := &scriptedProvider{responses: []*ChatCompletionResponse{
+		toolCallResponse("read", "read", "read", "read"),
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+	agent := NewAgent("Coder", "prompt", nil, ModelConfig{}, provider,
+		func(ctx context.Context, toolName string, args map[string]any) (string, error) {
+			if calls.Add(1) == 1 {
+				cancel()
+			}
+			<-ctx.Done()
+			return "", fmt.Errorf("tool execution interrupted")
+		}, nil)
+	agent.SetParallelTools(isRead, 2)
+	agent.AddMessage("user", "go")
+
+	_, err := agent.Run(ctx)
+	if err == nil || !strings.Contains(err.Error(), "interrupted") {
+		t.Fatalf("expected the run to be interrupted, got %v", err)
+	}
+	if got := calls.Load(); got > 2 {
+		t.Errorf("expected no new tool calls after cancellation, got %d calls", got)
+	}
+}
@@ -0,0 +1,408 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultAnthropicEndpoint is the base URL of the Anthropic API
+	DefaultAnthropicEndpoint = "https://api.anthropic.com/v1"
+
+	anthropicVersion = "2023-06-01"
+
+	// The Messages API requires max_tokens on every request
+	anthropicDefaultMaxTokens = 8192
+)
+
+// AnthropicClient is a Provider that speaks the Anthropic Messages API
+type AnthropicClient struct {
+	baseURL      string
+	apiKey       string
+	httpClient   *http.Client
+	streamClient *http.Client
+	logger       APILogger
+	retry        RetryConfig
+}
+
+// NewAnthropicClient creates a new Anthropic Messages API client
+func NewAnthropicClient(baseURL, apiKey string, logger APILogger) *AnthropicClient {
+	if baseURL == "" {
+		baseURL = DefaultAnthropicEndpoint
+	}
+
+	return &AnthropicClient{
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		httpClient: &http.Client{
+			Timeout: defaultTimeout,
+		},
+		streamClient: &http.Client{},
+		logger:       logger,
+	}
+}
+
+// anthropicRequest is the request body of the Messages API
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+// anthropicContentBlock is a text, tool_use or tool_result block
+type anthropicContentBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicResponse is the response body of the Messages API
+type anthropicResponse struct {
+	ID         string                  `json:"id"`
+	Type       string                  `json:"type"`
+	Role       string                  `json:"role"`
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      anthropicUsage          `json:"usage"`
+}
+
+// anthropicStreamEvent is a server-sent event of a streamed response
+type anthropicStreamEvent struct {
+	Type         string                `json:"type"`
+	Index        int                   `json:"index"`
+	Message      anthropicResponse     `json:"message"`
+	ContentBlock anthropicContentBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// CreateChatCompletion creates a chat completion with context for cancellation
+func (c *AnthropicClient) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	anthropicReq := toAnthropicRequest(req)
+
+	resp, err := c.send(ctx, c.httpClient, anthropicReq)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			fmt.Printf("Error closing response body: %v\n", err)
+		}
+	}(resp.Body)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		err := fmt.Errorf("reading response: %w", err)
+		if c.logger != nil {
+			c.logger.LogInteraction(anthropicReq, nil, err)
+		}
+		return nil, err
+	}
+
+	var respData anthropicResponse
+	if err := json.Unmarshal(body, &respData); err != nil {
+		return nil, fmt.Errorf("unmarshaling response: %w", err)
+	}
+
+	// Log the interaction
+	if c.logger != nil {
+		c.logger.LogInteraction(anthropicReq, respData, nil)
+	}
+
+	return fromAnthropicResponse(respData), nil
+}
+
+// CreateChatCompletionStream creates a streamed chat completion, calling
+// onDelta with assistant text as it arrives
+func (c *AnthropicClient) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest, onDelta func(delta string)) (*ChatCompletionResponse, error) {
+	anthropicReq := toAnthropicRequest(req)
+	anthropicReq.Stream = true
+
+	resp, err := c.send(ctx, c.streamClient, anthropicReq)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			fmt.Printf("Error closing response body: %v\n", err)
+		}
+	}(resp.Body)
+
+	respData, err := readAnthropicStream(resp.Body, onDelta)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("request cancelled: %w", ctx.Err())
+		}
+		if c.logger != nil {
+			c.logger.LogInteraction(anthropicReq, nil, err)
+		}
+		return nil, err
+	}
+
+	// Log the interaction
+	if c.logger != nil {
+		c.logger.LogInteraction(anthropicReq, respData, nil)
+	}
+
+	return fromAnthropicResponse(*respData), nil
+}
+
+// send posts a Messages API request and returns the response if the server
+// answered with 200 OK. The caller must close the response body.
+func (c *AnthropicClient) send(ctx context.Context, httpClient *http.Client, req anthropicRequest) (*http.Response, error) {
+	headers := map[string]string{
+		"x-api-key":         c.apiKey,
+		"anthropic-version": anthropicVersion,
+	}
+	if req.Stream {
+		headers["Accept"] = "text/event-stream"
+	}
+	return post(ctx, httpClient, c.retry, c.baseURL+"/messages", headers, req, c.logger)
+}
+
+// readAnthropicStream reads server-sent events from r and assembles them into a response
+func readAnthropicStream(r io.Reader, onDelta func(delta string)) (*anthropicResponse, error) {
+	var resp anthropicResponse
+	// Tool input arrives as partial JSON fragments, keyed by block index
+	partialInputs := make(map[int]*strings.Builder)
+	reader := bufio.NewReader(r)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("reading stream: %w", err)
+		}
+
+		line = strings.TrimSpace(line)
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			var event anthropicStreamEvent
+			if jsonErr := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); jsonErr != nil {
+				return nil, fmt.Errorf("unmarshaling stream event: %w", jsonErr)
+			}
+
+			switch event.Type {
+			case "message_start":
+				resp = event.Message
+				resp.Content = nil
+			case "content_block_start":
+				for len(resp.Content) <= event.Index {
+					resp.Content = append(resp.Content, anthropicContentBlock{})
+				}
+				resp.Content[event.Index] = event.ContentBlock
+				if event.ContentBlock.Type == "tool_use" {
+					resp.Content[event.Index].Input = nil
+					partialInputs[event.Index] = &strings.Builder{}
+				}
+			case "content_block_delta":
+				if event.Index >= len(resp.Content) {
+					return nil, fmt.Errorf("delta for unknown content block %d", event.Index)
+				}
+				switch event.Delta.Type {
+				case "text_delta":
+					resp.Content[event.Index].Text += event.Delta.Text
+					if onDelta != nil {
+						onDelta(event.Delta.Text)
+					}
+				case "input_json_delta":
+					partialInputs[event.Index].WriteString(event.Delta.PartialJSON)
+				}
+			case "content_block_stop":
+				if input, ok := partialInputs[event.Index]; ok && input.Len() > 0 {
+					resp.Content[event.Index].Input = json.RawMessage(input.String())
+				}
+			case "message_delta":
+				resp.StopReason = event.Delta.StopReason
+				resp.Usage.OutputTokens = event.Usage.OutputTokens
+			case "message_stop":
+				return &resp, nil
+			case "error":
+				return nil, fmt.Errorf("API error: %s", event.Error.Message)
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return &resp, nil
+}
+
+// toAnthropicRequest translates a chat completion request into a Messages API request
+func toAnthropicRequest(req ChatCompletionRequest) anthropicRequest {
+	anthropicReq := anthropicRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+	if anthropicReq.MaxTokens == 0 {
+		anthropicReq.MaxTokens = anthropicDefaultMaxTokens
+	}
+
+	var system []string
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system":
+			system = append(system, msg.Content)
+		case "assistant":
+			var blocks []anthropicContentBlock
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicContentBlock{Type: "text", Text: msg.Content})
+			}
+			for _, toolCall := range msg.ToolCalls {
+				input := json.RawMessage(toolCall.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicContentBlock{
+					Type:  "tool_use",
+					ID:    toolCall.ID,
+					Name:  toolCall.Function.Name,
+					Input: input,
+				})
+			}
+			anthropicReq.Messages = appendAnthropicMessage(anthropicReq.Messages, "assistant", blocks)
+		case "tool":
+			anthropicReq.Messages = appendAnthropicMessage(anthropicReq.Messages, "user", []anthropicContentBlock{{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   msg.Content,
+			}})
+		default:
+			anthropicReq.Messages = appendAnthropicMessage(anthropicReq.Messages, "user", []anthropicContentBlock{{
+				Type: "text",
+				Text: msg.Content,
+			}})
+		}
+	}
+	anthropicReq.System = strings.Join(system, "\n\n")
+
+	for _, tool := range req.Tools {
+		anthropicReq.Tools = append(anthropicReq.Tools, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: tool.Function.Parameters,
+		})
+	}
+
+	return anthropicReq
+}
+
+// appendAnthropicMessage appends blocks to the conversation, merging them into
+// the previous message when it has the same role. The Messages API expects
+// alternating turns, and all results for one assistant turn in a single message.
+func appendAnthropicMessage(messages []anthropicMessage, role string, blocks []anthropicContentBlock) []anthropicMessage {
+	if len(blocks) == 0 {
+		return messages
+	}
+
+	if n := len(messages); n > 0 && messages[n-1].Role == role {
+		messages[n-1].Content = append(messages[n-1].Content, blocks...)
+		return messages
+	}
+
+	return append(messages, anthropicMessage{Role: role, Content: blocks})
+}
+
+// fromAnthropicResponse translates a Messages API response into a chat completion response
+func fromAnthropicResponse(resp anthropicResponse) *ChatCompletionResponse {
+	message := Message{Role: "assistant"}
+
+	var text []string
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			text = append(text, block.Text)
+		case "tool_use":
+			arguments := string(block.Input)
+			if arguments == "" {
+				arguments = "{}"
+			}
+			message.ToolCalls = append(message.ToolCalls, ToolCall{
+				ID:   block.ID,
+				Type: "function",
+				Function: FunctionCall{
+					Name:      block.Name,
+					Arguments: arguments,
+				},
+			})
+		}
+	}
+	message.Content = strings.Join(text, "")
+
+	return &ChatCompletionResponse{
+		ID:     resp.ID,
+		Object: "chat.completion",
+		Choices: []ChatCompletionChoice{
+			{
+				Index:        0,
+				Message:      message,
+				FinishReason: anthropicFinishReason(resp.StopReason),
+			},
+		},
+		Usage: Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+	}
+}
+
+// anthropicFinishReason maps a Messages API stop_reason to a chat completion finish_reason
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "tool_use":
+		return "tool_calls"
+	case "max_tokens":
+		return "length"
+	default:
+		// end_turn, stop_sequence, pause_turn and refusal all end the turn
+		return "stop"
+	}
+}
@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnthropicClient_CreateChatCompletion(t *testing.T) {
+	var received anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			t.Errorf("expected path /messages, got %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" {
+			t.Errorf("expected x-api-key header to be set")
+		}
+		if r.Header.Get("anthropic-version") == "" {
+			t.Errorf("expected anthropic-version header to be set")
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Fatalf("invalid request body: %v", err)
+		}
+
+		fmt.Fprint(w, `{
+			"id": "msg_1",
+			"type": "message",
+			"role": "assistant",
+			"content": [
+				{"type": "text", "text": "Reading the file."},
+				{"type": "tool_use", "id": "toolu_2", "name": "read", "input": {"path": "go.mod"}}
+			],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 20, "output_tokens": 7}
+		}`)
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient(server.URL, "test-key", nil)
+
+	req := ChatCompletionRequest{
+		Model: "claude-test",
+		Messages: []Message{
+			{Role: "system", Content: "You are a test."},
+			{Role: "user", Content: "What is in main.go and go.mod?"},
+			{Role: "assistant", ToolCalls: []ToolCall{
+				{ID: "toolu_0", Type: "function", Function: FunctionCall{Name: "read", Arguments: `{"path":"main.go"}`}},
+				{ID: "toolu_1", Type: "function", Function: FunctionCall{Name: "ls", Arguments: `{"path":"."}`}},
+			}},
+			{Role: "tool", ToolCallID: "toolu_0", Content: "package main"},
+			{Role: "tool", ToolCallID: "toolu_1", Content: "main.go\ngo.mod"},
+		},
+		Tools: []Tool{
+			{Type: "function", Function: FunctionDefinition{Name: "read", Description: "Read a file", Parameters: map[string]any{"type": "object"}}},
+		},
+	}
+
+	resp, err := client.CreateChatCompletion(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Request translation
+	if received.System != "You are a test." {
+		t.Errorf("expected system prompt to be lifted out of messages, got %q", received.System)
+	}
+	if received.MaxTokens == 0 {
+		t.Errorf("expected max_tokens to be set")
+	}
+	if len(received.Messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d: %+v", len(received.Messages), received.Messages)
+	}
+	if got := received.Messages[1]; got.Role != "assistant" || len(got.Content) != 2 || got.Content[0].Type != "tool_use" {
+		t.Errorf("expected assistant message with two tool_use blocks, got %+v", got)
+	}
+	results := received.Messages[2]
+	if results.Role != "user" || len(results.Content) != 2 {
+		t.Fatalf("expected tool results merged into one user message, got %+v", results)
+	}
+	if results.Content[0].Type != "tool_result" || results.Content[0].ToolUseID != "toolu_0" || results.Content[0].Content != "package main" {
+		t.Errorf("unexpected tool_result block: %+v", results.Content[0])
+	}
+	if len(received.Tools) != 1 || received.Tools[0].Name != "read" || received.Tools[0].InputSchema == nil {
+		t.Errorf("unexpected tools: %+v", received.Tools)
+	}
+
+	// Response translation
+	want := Message{
+		Role:    "assistant",
+		Content: "Reading the file.",
+		ToolCalls: []ToolCall{
+			{ID: "toolu_2", Type: "function", Function: FunctionCall{Name: "read", Arguments: `{"path": "go.mod"}`}},
+		},
+	}
+	if !reflect.DeepEqual(resp.Choices[0].Message, want) {
+		t.Errorf("expected message %+v, got %+v", want, resp.Choices[0].Message)
+	}
+	if resp.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("expected finish reason tool_calls, got %q", resp.Choices[0].FinishReason)
+	}
+	if resp.Usage.PromptTokens != 20 || resp.Usage.CompletionTokens != 7 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicClient_CreateChatCompletionStream(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"usage":{"input_tokens":12,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" there"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"ls","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\".\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}`,
+		`{"type":"message_stop"}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			var typed struct {
+				Type string `json:"type"`
+			}
+			_ = json.Unmarshal([]byte(event), &typed)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typed.Type, event)
+		}
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient(server.URL, "test-key", nil)
+
+	var deltas []string
+	resp, err := client.CreateChatCompletionStream(context.Background(), ChatCompletionRequest{Model: "claude-test"}, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := strings.Join(deltas, ""); got != "Hello there" {
+		t.Errorf("expected streamed text %q, got %q", "Hello there", got)
+	}
+
+	want := Message{
+		Role:    "assistant",
+		Content: "Hello there",
+		ToolCalls: []ToolCall{
+			{ID: "toolu_1", Type: "function", Function: FunctionCall{Name: "ls", Arguments: `{"path":"."}`}},
+		},
+	}
+	if !reflect.DeepEqual(resp.Choices[0].Message, want) {
+		t.Errorf("expected message %+v, got %+v", want, resp.Choices[0].Message)
+	}
+	if resp.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("expected finish reason tool_calls, got %q", resp.Choices[0].FinishReason)
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 9 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicFinishReason(t *testing.T) {
+	tests := map[string]string{
+		"end_turn":      "stop",
+		"stop_sequence": "stop",
+		"tool_use":      "tool_calls",
+		"max_tokens":    "length",
+	}
+
+	for stopReason, want := range tests {
+		if got := anthropicFinishReason(stopReason); got != want {
+			t.Errorf("anthropicFinishReason(%q) = %q, want %q", stopReason, got, want)
+		}
+	}
+}
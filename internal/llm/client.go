@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -19,7 +20,18 @@ type Client struct {
 	baseURL    string
 	apiKey     string
 	httpClient *http.Client
-	logger     APILogger
+	// streamClient has no overall timeout since a streamed response may
+	// legitimately take longer than defaultTimeout; cancellation is left to ctx
+	streamClient *http.Client
+	logger       APILogger
+	retry        RetryConfig
+
+	// local enables capability detection for local OpenAI compatible servers
+	// such as Ollama or llama.cpp, see local.go
+	local       bool
+	toolCalling string
+	mu          sync.Mutex
+	nativeTools map[string]bool // Detected tool support by model
 }
 
 // NewClient creates a new OpenAI API client
@@ -30,7 +42,8 @@ func NewClient(baseURL, apiKey string, logger APILogger) *Client {
 		httpClient: &http.Client{
 			Timeout: defaultTimeout,
 		},
-		logger: logger,
+		streamClient: &http.Client{},
+		logger:       logger,
 	}
 }
 
@@ -68,6 +81,14 @@ type ChatCompletionRequest struct {
 	Tools       []Tool    `json:"tools,omitempty"`
 	Temperature float64   `json:"temperature,omitempty"`
 	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+	// StreamOptions is only sent with streaming requests
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+// StreamOptions configures a streaming chat completion
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // ToolCall represents a tool call by the model
@@ -84,48 +105,33 @@ type ChatCompletionChoice struct {
 	FinishReason string  `json:"finish_reason"`
 }
 
+// Usage reports the number of tokens used by a request
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
 // ChatCompletionResponse is the response structure for chat completions
 type ChatCompletionResponse struct {
 	ID      string                 `json:"id"`
 	Object  string                 `json:"object"`
 	Created int64                  `json:"created"`
 	Choices []ChatCompletionChoice `json:"choices"`
-	Usage   struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage   Usage                  `json:"usage"`
 }
 
 // CreateChatCompletion creates a chat completion with context for cancellation
 func (c *Client) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (*ChatCompletionResponse, error) {
-	reqBody, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("marshaling request: %w", err)
+	if c.local && len(req.Tools) > 0 {
+		return c.createLocalChatCompletion(ctx, req, nil)
 	}
+	return c.createChatCompletion(ctx, req)
+}
 
-	// Create the request with the provided context
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
-
-	resp, err := c.httpClient.Do(httpReq)
+func (c *Client) createChatCompletion(ctx context.Context, req ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	resp, err := c.send(ctx, c.httpClient, req)
 	if err != nil {
-		// Check if the error was caused by context cancellation
-		if ctx.Err() != nil {
-			if c.logger != nil {
-				c.logger.LogInteraction(req, nil, fmt.Errorf("request cancelled: %w", ctx.Err()))
-			}
-			return nil, fmt.Errorf("request cancelled: %w", ctx.Err())
-		}
-		err := fmt.Errorf("request error: %w", err)
-		if c.logger != nil {
-			c.logger.LogInteraction(req, nil, err)
-		}
 		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
@@ -144,25 +150,6 @@ func (c *Client) CreateChatCompletion(ctx context.Context, req ChatCompletionReq
 		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		var errResp struct {
-			Error struct {
-				Message string `json:"message"`
-				Type    string `json:"type"`
-			} `json:"error"`
-		}
-		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
-			if c.logger != nil {
-				c.logger.LogInteraction(req, nil, fmt.Errorf("API error: %s", errResp.Error.Message))
-			}
-			return nil, fmt.Errorf("API error: %s", errResp.Error.Message)
-		}
-		if c.logger != nil {
-			c.logger.LogInteraction(req, nil, fmt.Errorf("API error: %s", string(body)))
-		}
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
 	var respData ChatCompletionResponse
 	if err := json.Unmarshal(body, &respData); err != nil {
 		return nil, fmt.Errorf("unmarshaling response: %w", err)
@@ -175,3 +162,137 @@ func (c *Client) CreateChatCompletion(ctx context.Context, req ChatCompletionReq
 
 	return &respData, nil
 }
+
+// send posts a chat completion request and returns the response if the
+// server answered with 200 OK. The caller must close the response body.
+func (c *Client) send(ctx context.Context, httpClient *http.Client, req ChatCompletionRequest) (*http.Response, error) {
+	headers := map[string]string{
+		"Authorization": "Bearer " + c.apiKey,
+	}
+	if req.Stream {
+		headers["Accept"] = "text/event-stream"
+	}
+	return post(ctx, httpClient, c.retry, c.baseURL+"/chat/completions", headers, req, c.logger)
+}
+
+// post sends req as JSON to url and returns the response if the server
+// answered with 200 OK. Transient failures are retried according to retry.
+// Failures are logged with req as the request. The caller must close the
+// response body.
+func post(ctx context.Context, httpClient *http.Client, retry RetryConfig, url string, headers map[string]string, req any, logger APILogger) (*http.Response, error) {
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, reqErr := postOnce(ctx, httpClient, url, headers, reqBody)
+		if reqErr == nil {
+			return resp, nil
+		}
+
+		delay, ok := retry.delay(attempt, reqErr.retryAfter)
+		if !reqErr.retryable || !ok || attempt > retry.MaxRetries || ctx.Err() != nil {
+			if logger != nil {
+				logger.LogInteraction(req, nil, reqErr.detail)
+			}
+			return nil, reqErr.err
+		}
+
+		if logger != nil {
+			logger.LogInteraction(req, nil, fmt.Errorf("attempt %d of %d failed, retrying in %s: %w",
+				attempt, retry.MaxRetries+1, delay, reqErr.detail))
+		}
+		if retry.OnRetry != nil {
+			retry.OnRetry(attempt, delay, reqErr.err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err := fmt.Errorf("request cancelled: %w", ctx.Err())
+			if logger != nil {
+				logger.LogInteraction(req, nil, err)
+			}
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// postOnce makes a single attempt at posting reqBody to url
+func postOnce(ctx context.Context, httpClient *http.Client, url string, headers map[string]string, reqBody []byte) (*http.Response, *requestError) {
+	// Create the request with the provided context
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, newRequestError(fmt.Errorf("creating request: %w", err))
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		// Check if the error was caused by context cancellation
+		if ctx.Err() != nil {
+			return nil, newRequestError(fmt.Errorf("request cancelled: %w", ctx.Err()))
+		}
+		// The server never answered, so the request can safely be sent again
+		reqErr := newRequestError(fmt.Errorf("request error: %w", err))
+		reqErr.retryable = true
+		return nil, reqErr
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newRequestError(fmt.Errorf("reading response: %w", err))
+	}
+
+	var reqErr *requestError
+	if message := apiErrorMessage(body); message != "" {
+		reqErr = newRequestError(fmt.Errorf("API error: %s", message))
+	} else {
+		reqErr = &requestError{
+			err:    fmt.Errorf("unexpected status code: %d", resp.StatusCode),
+			detail: fmt.Errorf("API error: %s", string(body)),
+		}
+	}
+	reqErr.retryable = isRetryableStatus(resp.StatusCode)
+	reqErr.retryAfter = parseRetryAfter(resp.Header, time.Now())
+
+	return nil, reqErr
+}
+
+// apiErrorMessage extracts the error message from an error response body.
+// OpenAI and Anthropic report errors as {"error": {"message": ...}}, Ollama
+// and llama.cpp sometimes as {"error": "..."}.
+func apiErrorMessage(body []byte) string {
+	var errResp struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error == nil {
+		return ""
+	}
+
+	var message string
+	if err := json.Unmarshal(errResp.Error, &message); err == nil {
+		return message
+	}
+
+	var errObj struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	}
+	if err := json.Unmarshal(errResp.Error, &errObj); err == nil {
+		return errObj.Message
+	}
+	return ""
+}
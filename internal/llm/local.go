@@ -0,0 +1,378 @@
+package llm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// DefaultLocalEndpoint is the OpenAI compatible endpoint of a local Ollama server
+const DefaultLocalEndpoint = "http://localhost:11434/v1"
+
+// NewLocalClient creates a client for a local OpenAI compatible server such as
+// Ollama or llama.cpp. Many local models do not support native tool calls;
+// depending on toolCalling the client detects this and falls back to
+// describing the tools in the system prompt and parsing calls from the reply.
+func NewLocalClient(baseURL, apiKey, toolCalling string, logger APILogger) *Client {
+	if baseURL == "" {
+		baseURL = DefaultLocalEndpoint
+	}
+	if toolCalling == "" {
+		toolCalling = ToolCallingAuto
+	}
+
+	client := NewClient(baseURL, apiKey, logger)
+	client.local = true
+	client.toolCalling = toolCalling
+	client.nativeTools = make(map[string]bool)
+	return client
+}
+
+// createLocalChatCompletion creates a chat completion that uses native tool
+// calls when the model supports them and the text protocol otherwise.
+// onDelta may be nil for a non-streamed completion.
+func (c *Client) createLocalChatCompletion(ctx context.Context, req ChatCompletionRequest, onDelta func(delta string)) (*ChatCompletionResponse, error) {
+	if c.supportsNativeTools(ctx, req.Model) {
+		var resp *ChatCompletionResponse
+		var err error
+		if onDelta != nil {
+			resp, err = c.createChatCompletionStream(ctx, req, onDelta)
+		} else {
+			resp, err = c.createChatCompletion(ctx, req)
+		}
+
+		// Servers that can't tell us up front reject the tools field instead
+		if err == nil || c.toolCalling == ToolCallingNative || !isToolsUnsupportedError(err) {
+			return resp, err
+		}
+		c.setNativeTools(req.Model, false)
+	}
+
+	textReq := toTextToolRequest(req)
+
+	var resp *ChatCompletionResponse
+	var err error
+	if onDelta != nil {
+		filter := &toolCallFilter{onDelta: onDelta}
+		resp, err = c.createChatCompletionStream(ctx, textReq, filter.write)
+		filter.flush()
+	} else {
+		resp, err = c.createChatCompletion(ctx, textReq)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range resp.Choices {
+		choice := &resp.Choices[i]
+		content, toolCalls := parseTextToolCalls(choice.Message.Content)
+		if len(toolCalls) > 0 {
+			choice.Message.Content = content
+			choice.Message.ToolCalls = toolCalls
+			choice.FinishReason = "tool_calls"
+		}
+	}
+
+	return resp, nil
+}
+
+// supportsNativeTools reports whether model accepts the tools field
+func (c *Client) supportsNativeTools(ctx context.Context, model string) bool {
+	switch c.toolCalling {
+	case ToolCallingNative:
+		return true
+	case ToolCallingText:
+		return false
+	}
+
+	c.mu.Lock()
+	supported, ok := c.nativeTools[model]
+	c.mu.Unlock()
+	if ok {
+		return supported
+	}
+
+	supported, known := c.detectNativeTools(ctx, model)
+	if !known {
+		// Assume tools work until the server rejects them
+		return true
+	}
+
+	c.setNativeTools(model, supported)
+	return supported
+}
+
+func (c *Client) setNativeTools(model string, supported bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nativeTools[model] = supported
+}
+
+// detectNativeTools asks an Ollama server for the capabilities of model. known
+// is false when the server doesn't provide this information.
+func (c *Client) detectNativeTools(ctx context.Context, model string) (supported bool, known bool) {
+	root := strings.TrimSuffix(strings.TrimRight(c.baseURL, "/"), "/v1")
+
+	resp, err := post(ctx, c.httpClient, RetryConfig{}, root+"/api/show", nil, map[string]string{"model": model}, nil)
+	if err != nil {
+		return false, false
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, false
+	}
+
+	var show struct {
+		Capabilities []string `json:"capabilities"`
+		Template     string   `json:"template"`
+	}
+	if err := json.Unmarshal(body, &show); err != nil {
+		return false, false
+	}
+
+	if show.Capabilities != nil {
+		for _, capability := range show.Capabilities {
+			if capability == "tools" {
+				return true, true
+			}
+		}
+		return false, true
+	}
+
+	// Older Ollama versions don't report capabilities, but only templates of
+	// tool capable models render the tool list
+	if show.Template != "" {
+		return strings.Contains(show.Template, ".Tools"), true
+	}
+
+	return false, false
+}
+
+// isToolsUnsupportedError reports whether err is a server rejecting the tools field
+func isToolsUnsupportedError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "does not support tools") ||
+		strings.Contains(msg, "tools not supported") ||
+		strings.Contains(msg, "tools are not supported")
+}
+
+// Tool calls in the text protocol are JSON objects wrapped in one of these
+const (
+	toolCallOpenTag  = "<tool_call>"
+	toolCallCloseTag = "</tool_call>"
+	toolCallFence    = "```tool_call"
+)
+
+var textToolCallPattern = regexp.MustCompile("(?s)<tool_call>\\s*(.*?)\\s*(?:</tool_call>|$)|```tool_call\\s*(.*?)\\s*```")
+
+// toTextToolRequest rewrites req for a model without native tool support. The
+// tools are described in the system prompt and earlier tool calls and results
+// are rendered as text in the protocol the model is asked to use.
+func toTextToolRequest(req ChatCompletionRequest) ChatCompletionRequest {
+	textReq := req
+	textReq.Tools = nil
+	textReq.Messages = nil
+
+	instructions := renderToolInstructions(req.Tools)
+	hasSystem := false
+
+	for _, msg := range req.Messages {
+		switch {
+		case msg.Role == "system" && !hasSystem:
+			hasSystem = true
+			textReq.Messages = append(textReq.Messages, Message{
+				Role:    "system",
+				Content: msg.Content + "\n\n" + instructions,
+			})
+		case msg.Role == "assistant" && len(msg.ToolCalls) > 0:
+			var content strings.Builder
+			content.WriteString(msg.Content)
+			for _, toolCall := range msg.ToolCalls {
+				if content.Len() > 0 {
+					content.WriteString("\n")
+				}
+				content.WriteString(renderTextToolCall(toolCall))
+			}
+			textReq.Messages = append(textReq.Messages, Message{Role: "assistant", Content: content.String()})
+		case msg.Role == "tool":
+			result := fmt.Sprintf("<tool_result id=%q>\n%s\n</tool_result>", msg.ToolCallID, msg.Content)
+			// Keep all results of one turn in a single message
+			if n := len(textReq.Messages); n > 0 && textReq.Messages[n-1].Role == "user" &&
+				strings.HasPrefix(textReq.Messages[n-1].Content, "<tool_result") {
+				textReq.Messages[n-1].Content += "\n" + result
+			} else {
+				textReq.Messages = append(textReq.Messages, Message{Role: "user", Content: result})
+			}
+		default:
+			textReq.Messages = append(textReq.Messages, Message{Role: msg.Role, Content: msg.Content})
+		}
+	}
+
+	if !hasSystem {
+		textReq.Messages = append([]Message{{Role: "system", Content: instructions}}, textReq.Messages...)
+	}
+
+	return textReq
+}
+
+// renderToolInstructions describes tools and the text tool call protocol
+func renderToolInstructions(tools []Tool) string {
+	var b strings.Builder
+	b.WriteString("# Tools\n\n")
+	b.WriteString("You can use the following tools. Each tool takes a JSON object matching its parameters schema.\n\n")
+
+	for _, tool := range tools {
+		b.WriteString("## " + tool.Function.Name + "\n")
+		if tool.Function.Description != "" {
+			b.WriteString(tool.Function.Description + "\n")
+		}
+		if params, err := json.Marshal(tool.Function.Parameters); err == nil {
+			b.WriteString("Parameters: " + string(params) + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("To use a tool, reply with one or more tool calls in exactly this format:\n")
+	b.WriteString(toolCallOpenTag + "\n")
+	b.WriteString(`{"name": "tool_name", "arguments": {"parameter": "value"}}` + "\n")
+	b.WriteString(toolCallCloseTag + "\n\n")
+	b.WriteString("Do not write anything after your tool calls. The results will be sent back to you in <tool_result> blocks. ")
+	b.WriteString("When you don't need a tool, reply normally without a tool call.")
+
+	return b.String()
+}
+
+// renderTextToolCall renders a tool call in the text protocol
+func renderTextToolCall(toolCall ToolCall) string {
+	arguments := json.RawMessage(toolCall.Function.Arguments)
+	if !json.Valid(arguments) {
+		arguments = json.RawMessage("{}")
+	}
+
+	call, _ := json.Marshal(struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}{toolCall.Function.Name, arguments})
+
+	return toolCallOpenTag + "\n" + string(call) + "\n" + toolCallCloseTag
+}
+
+// parseTextToolCalls extracts tool calls written in the text protocol from
+// content. It returns the content without the tool calls.
+func parseTextToolCalls(content string) (string, []ToolCall) {
+	var toolCalls []ToolCall
+
+	remaining := textToolCallPattern.ReplaceAllStringFunc(content, func(block string) string {
+		groups := textToolCallPattern.FindStringSubmatch(block)
+		body := groups[1]
+		if body == "" {
+			body = groups[2]
+		}
+
+		var call struct {
+			Name       string          `json:"name"`
+			Arguments  json.RawMessage `json:"arguments"`
+			Parameters json.RawMessage `json:"parameters"`
+		}
+		if err := json.Unmarshal([]byte(body), &call); err != nil || call.Name == "" {
+			// Not a tool call, leave it in the message
+			return block
+		}
+
+		arguments := call.Arguments
+		if arguments == nil {
+			arguments = call.Parameters
+		}
+		// Some models encode the arguments as a JSON string
+		var encoded string
+		if err := json.Unmarshal(arguments, &encoded); err == nil {
+			arguments = json.RawMessage(encoded)
+		}
+		if !json.Valid(arguments) {
+			arguments = json.RawMessage("{}")
+		}
+
+		toolCalls = append(toolCalls, ToolCall{
+			ID:   newToolCallID(),
+			Type: "function",
+			Function: FunctionCall{
+				Name:      call.Name,
+				Arguments: string(arguments),
+			},
+		})
+		return ""
+	})
+
+	return strings.TrimSpace(remaining), toolCalls
+}
+
+// newToolCallID generates an ID for a tool call parsed from text
+func newToolCallID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "call_" + hex.EncodeToString(b)
+}
+
+// toolCallFilter forwards streamed text but holds back tool calls written in
+// the text protocol, so that they aren't shown to the user as they arrive
+type toolCallFilter struct {
+	onDelta    func(delta string)
+	pending    string
+	suppressed bool
+}
+
+func (f *toolCallFilter) write(delta string) {
+	if f.suppressed {
+		return
+	}
+
+	text := f.pending + delta
+	f.pending = ""
+
+	start := -1
+	for _, marker := range []string{toolCallOpenTag, toolCallFence} {
+		if i := strings.Index(text, marker); i >= 0 && (start < 0 || i < start) {
+			start = i
+		}
+	}
+	if start >= 0 {
+		f.emit(text[:start])
+		f.suppressed = true
+		return
+	}
+
+	// Hold back a trailing partial marker until the next delta decides it
+	keep := 0
+	for _, marker := range []string{toolCallOpenTag, toolCallFence} {
+		for n := len(marker) - 1; n > keep; n-- {
+			if strings.HasSuffix(text, marker[:n]) {
+				keep = n
+				break
+			}
+		}
+	}
+
+	f.emit(text[:len(text)-keep])
+	f.pending = text[len(text)-keep:]
+}
+
+// flush forwards any text held back at the end of the stream
+func (f *toolCallFilter) flush() {
+	if !f.suppressed {
+		f.emit(f.pending)
+	}
+	f.pending = ""
+}
+
+func (f *toolCallFilter) emit(text string) {
+	if text != "" {
+		f.onDelta(text)
+	}
+}
@@ -0,0 +1,158 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTextToolCalls(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantContent string
+		wantCalls   []FunctionCall
+	}{
+		{
+			name:        "no tool calls",
+			content:     "Just an answer.",
+			wantContent: "Just an answer.",
+		},
+		{
+			name:        "tagged tool call",
+			content:     "Let me look.\n<tool_call>\n{\"name\": \"read\", \"arguments\": {\"path\": \"main.go\"}}\n</tool_call>",
+			wantContent: "Let me look.",
+			wantCalls:   []FunctionCall{{Name: "read", Arguments: `{"path": "main.go"}`}},
+		},
+		{
+			name:        "fenced tool calls with string arguments",
+			content:     "```tool_call\n{\"name\": \"ls\", \"arguments\": \"{\\\"path\\\": \\\".\\\"}\"}\n```\n```tool_call\n{\"name\": \"tree\", \"parameters\": {\"path\": \"internal\"}}\n```",
+			wantContent: "",
+			wantCalls: []FunctionCall{
+				{Name: "ls", Arguments: `{"path": "."}`},
+				{Name: "tree", Arguments: `{"path": "internal"}`},
+			},
+		},
+		{
+			name:        "unterminated tool call",
+			content:     "<tool_call>{\"name\": \"read\", \"arguments\": {\"path\": \"go.mod\"}}",
+			wantContent: "",
+			wantCalls:   []FunctionCall{{Name: "read", Arguments: `{"path": "go.mod"}`}},
+		},
+		{
+			name:        "invalid json is left alone",
+			content:     "<tool_call>not json</tool_call>",
+			wantContent: "<tool_call>not json</tool_call>",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			content, toolCalls := parseTextToolCalls(tc.content)
+			if content != tc.wantContent {
+				t.Errorf("expected content %q, got %q", tc.wantContent, content)
+			}
+			if len(toolCalls) != len(tc.wantCalls) {
+				t.Fatalf("expected %d tool calls, got %d", len(tc.wantCalls), len(toolCalls))
+			}
+			for i, call := range toolCalls {
+				if call.ID == "" || call.Type != "function" {
+					t.Errorf("tool call %d is missing id or type: %+v", i, call)
+				}
+				if call.Function != tc.wantCalls[i] {
+					t.Errorf("expected tool call %+v, got %+v", tc.wantCalls[i], call.Function)
+				}
+			}
+		})
+	}
+}
+
+func TestToolCallFilter(t *testing.T) {
+	var out strings.Builder
+	filter := &toolCallFilter{onDelta: func(delta string) { out.WriteString(delta) }}
+
+	for _, delta := range []string{"Checking", " now <to", "ol_c", "all>{\"name\":", "\"ls\"}</tool_call>"} {
+		filter.write(delta)
+	}
+	filter.flush()
+
+	if out.String() != "Checking now " {
+		t.Errorf("expected tool call to be held back, got %q", out.String())
+	}
+
+	out.Reset()
+	filter = &toolCallFilter{onDelta: func(delta string) { out.WriteString(delta) }}
+	filter.write("a < b and ")
+	filter.write("```go")
+	filter.flush()
+
+	if out.String() != "a < b and ```go" {
+		t.Errorf("expected ordinary text to pass through, got %q", out.String())
+	}
+}
+
+func TestLocalClient_TextToolFallback(t *testing.T) {
+	var received ChatCompletionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/show":
+			fmt.Fprint(w, `{"capabilities": ["completion"]}`)
+		case "/v1/chat/completions":
+			body, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &received); err != nil {
+				t.Fatalf("invalid request body: %v", err)
+			}
+			fmt.Fprint(w, `{"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant",
+				"content": "<tool_call>\n{\"name\": \"read\", \"arguments\": {\"path\": \"go.mod\"}}\n</tool_call>"}}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewLocalClient(server.URL+"/v1", "", ToolCallingAuto, nil)
+
+	req := ChatCompletionRequest{
+		Model: "gemma",
+		Messages: []Message{
+			{Role: "system", Content: "You are a test."},
+			{Role: "user", Content: "Read main.go"},
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Type: "function", Function: FunctionCall{Name: "read", Arguments: `{"path":"main.go"}`}}}},
+			{Role: "tool", ToolCallID: "call_1", Content: "package main"},
+		},
+		Tools: []Tool{{Type: "function", Function: FunctionDefinition{Name: "read", Description: "Read a file"}}},
+	}
+
+	resp, err := client.CreateChatCompletion(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(received.Tools) != 0 {
+		t.Errorf("expected tools to be omitted from the request")
+	}
+	if !strings.Contains(received.Messages[0].Content, "## read") {
+		t.Errorf("expected tools to be described in the system prompt, got %q", received.Messages[0].Content)
+	}
+	for _, msg := range received.Messages {
+		if msg.Role == "tool" || len(msg.ToolCalls) > 0 {
+			t.Errorf("expected tool messages to be rendered as text, got %+v", msg)
+		}
+	}
+	if last := received.Messages[len(received.Messages)-1]; last.Role != "user" || !strings.Contains(last.Content, "package main") {
+		t.Errorf("expected tool result as a user message, got %+v", last)
+	}
+
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" || len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("expected a parsed tool call, got %+v", choice)
+	}
+	if choice.Message.ToolCalls[0].Function.Name != "read" {
+		t.Errorf("unexpected tool call: %+v", choice.Message.ToolCalls[0])
+	}
+}
@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Provider types accepted by NewProvider
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderLocal     = "local"
+)
+
+// Tool calling modes for local providers
+const (
+	// ToolCallingAuto detects whether the model supports native tool calls
+	ToolCallingAuto = "auto"
+	// ToolCallingNative always sends tools using the API's tools field
+	ToolCallingNative = "native"
+	// ToolCallingText describes tools in the system prompt and parses calls from the reply
+	ToolCallingText = "text"
+)
+
+// Provider is a chat completion backend. Requests and responses use the
+// OpenAI chat completion shape; providers with a different wire format
+// translate to and from it.
+type Provider interface {
+	// CreateChatCompletion creates a chat completion with context for cancellation
+	CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (*ChatCompletionResponse, error)
+
+	// CreateChatCompletionStream creates a streamed chat completion, calling
+	// onDelta with assistant text as it arrives
+	CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest, onDelta func(delta string)) (*ChatCompletionResponse, error)
+}
+
+// ProviderOptions configures a provider created by NewProvider
+type ProviderOptions struct {
+	// Type is one of ProviderOpenAI, ProviderAnthropic or ProviderLocal.
+	// An empty type selects the OpenAI compatible client.
+	Type    string
+	BaseURL string
+	APIKey  string
+
+	// ToolCalling is the tool calling mode of local providers
+	ToolCalling string
+
+	// Retry controls how failed requests are retried
+	Retry RetryConfig
+}
+
+// NewProvider creates the provider for the given options
+func NewProvider(opts ProviderOptions, logger APILogger) (Provider, error) {
+	switch opts.Type {
+	case "", ProviderOpenAI:
+		client := NewClient(opts.BaseURL, opts.APIKey, logger)
+		client.retry = opts.Retry
+		return client, nil
+	case ProviderAnthropic:
+		client := NewAnthropicClient(opts.BaseURL, opts.APIKey, logger)
+		client.retry = opts.Retry
+		return client, nil
+	case ProviderLocal:
+		client := NewLocalClient(opts.BaseURL, opts.APIKey, opts.ToolCalling, logger)
+		client.retry = opts.Retry
+		return client, nil
+	default:
+		return nil, fmt.Errorf("unknown provider type: %s", opts.Type)
+	}
+}
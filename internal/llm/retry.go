@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRetryInitialDelay = 1 * time.Second
+	defaultRetryMaxDelay     = 30 * time.Second
+)
+
+// RetryConfig controls how failed API requests are retried. Only transient
+// failures are retried: rate limits, overloaded or unavailable servers and
+// requests that never got an answer. Streams are not retried once the
+// response has started.
+type RetryConfig struct {
+	// MaxRetries is the number of retries after the first attempt, 0 disables retries
+	MaxRetries int
+
+	// InitialDelay is the base delay before the first retry, doubled for
+	// every further retry up to MaxDelay. A request whose server asks to wait
+	// longer than MaxDelay isn't retried.
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+
+	// OnRetry is called before waiting for the next attempt
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// delay returns how long to wait before retrying after the given failed
+// attempt. A delay requested by the server takes precedence; ok is false when
+// it is longer than MaxDelay, and the request shouldn't be retried.
+func (r RetryConfig) delay(attempt int, retryAfter time.Duration) (delay time.Duration, ok bool) {
+	maxDelay := r.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultRetryMaxDelay
+	}
+	if retryAfter > 0 {
+		return retryAfter, retryAfter <= maxDelay
+	}
+
+	initial := r.InitialDelay
+	if initial <= 0 {
+		initial = defaultRetryInitialDelay
+	}
+
+	backoff := initial
+	for i := 1; i < attempt && backoff < maxDelay; i++ {
+		backoff *= 2
+	}
+	if backoff > maxDelay {
+		backoff = maxDelay
+	}
+
+	// Jitter between half and the full backoff so clients don't retry in lockstep
+	half := backoff / 2
+	return half + rand.N(half+1), true
+}
+
+// requestError is a failed API request
+type requestError struct {
+	err        error // Returned to the caller
+	detail     error // Recorded in the API log
+	retryable  bool
+	retryAfter time.Duration
+}
+
+func newRequestError(err error) *requestError {
+	return &requestError{err: err, detail: err}
+}
+
+// isRetryableStatus reports whether a response with this status code is a
+// transient failure that the server expects to be retried
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		529: // Anthropic: overloaded
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter returns the delay requested by the server, or 0 if there is none
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	// OpenAI sends a more precise value in milliseconds
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientRetriesTransientFailures(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxRetries   int
+		wantErr      bool
+		wantRequests int
+	}{
+		{name: "succeeds after transient failures", statuses: []int{429, 503, 200}, maxRetries: 3, wantRequests: 3},
+		{name: "gives up after max retries", statuses: []int{500, 502, 504}, maxRetries: 2, wantErr: true, wantRequests: 3},
+		{name: "does not retry client errors", statuses: []int{400, 200}, maxRetries: 3, wantErr: true, wantRequests: 1},
+		{name: "retries disabled", statuses: []int{503, 200}, maxRetries: 0, wantErr: true, wantRequests: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tc.statuses[requests]
+				requests++
+				if status != http.StatusOK {
+					w.Header().Set("retry-after-ms", "1")
+					w.WriteHeader(status)
+					fmt.Fprint(w, `{"error": {"message": "try again"}}`)
+					return
+				}
+				fmt.Fprint(w, `{"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": "ok"}}]}`)
+			}))
+			defer server.Close()
+
+			var retries []int
+			provider, err := NewProvider(ProviderOptions{
+				BaseURL: server.URL,
+				Retry: RetryConfig{
+					MaxRetries: tc.maxRetries,
+					OnRetry: func(attempt int, delay time.Duration, err error) {
+						retries = append(retries, attempt)
+					},
+				},
+			}, nil)
+			if err != nil {
+				t.Fatalf("creating provider: %v", err)
+			}
+
+			_, err = provider.CreateChatCompletion(context.Background(), ChatCompletionRequest{Model: "test"})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if requests != tc.wantRequests {
+				t.Errorf("expected %d requests, got %d", tc.wantRequests, requests)
+			}
+			if len(retries) != tc.wantRequests-1 {
+				t.Errorf("expected %d retry callbacks, got %v", tc.wantRequests-1, retries)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	retry := RetryConfig{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempt, base := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 5: time.Second} {
+		delay, ok := retry.delay(attempt, 0)
+		if !ok || delay < base/2 || delay > base {
+			t.Errorf("attempt %d: expected delay between %s and %s, got %s", attempt, base/2, base, delay)
+		}
+	}
+
+	if delay, ok := retry.delay(1, 500*time.Millisecond); !ok || delay != 500*time.Millisecond {
+		t.Errorf("expected Retry-After to take precedence, got %s", delay)
+	}
+	if _, ok := retry.delay(1, time.Hour); ok {
+		t.Error("expected no retry when Retry-After exceeds MaxDelay")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{name: "none", header: http.Header{}, want: 0},
+		{name: "seconds", header: http.Header{"Retry-After": {"3"}}, want: 3 * time.Second},
+		{name: "milliseconds", header: http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"1"}}, want: 250 * time.Millisecond},
+		{name: "http date", header: http.Header{"Retry-After": {now.Add(10 * time.Second).Format(http.TimeFormat)}}, want: 10 * time.Second},
+		{name: "date in the past", header: http.Header{"Retry-After": {now.Add(-time.Minute).Format(http.TimeFormat)}}, want: 0},
+		{name: "garbage", header: http.Header{"Retry-After": {"soon"}}, want: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseRetryAfter(tc.header, now); got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
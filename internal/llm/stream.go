@@ -0,0 +1,224 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ChatCompletionChunk is a single server-sent event of a streamed chat completion
+type ChatCompletionChunk struct {
+	ID      string                      `json:"id"`
+	Object  string                      `json:"object"`
+	Created int64                       `json:"created"`
+	Choices []ChatCompletionChunkChoice `json:"choices"`
+	Usage   *Usage                      `json:"usage,omitempty"`
+}
+
+// ChatCompletionChunkChoice is the incremental update for one choice
+type ChatCompletionChunkChoice struct {
+	Index        int          `json:"index"`
+	Delta        MessageDelta `json:"delta"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+// MessageDelta is a partial message received while streaming
+type MessageDelta struct {
+	Role      string          `json:"role,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+// ToolCallDelta is a fragment of a tool call. Fragments belonging to the same
+// call share an Index; the ID and name arrive once and the arguments are
+// split across several fragments.
+type ToolCallDelta struct {
+	Index    int          `json:"index"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+// CreateChatCompletionStream creates a streamed chat completion. onDelta is
+// called with every piece of assistant text as it arrives. The returned
+// response is assembled from the stream and has the same shape as the one
+// returned by CreateChatCompletion.
+func (c *Client) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest, onDelta func(delta string)) (*ChatCompletionResponse, error) {
+	if c.local && len(req.Tools) > 0 {
+		return c.createLocalChatCompletion(ctx, req, onDelta)
+	}
+	return c.createChatCompletionStream(ctx, req, onDelta)
+}
+
+func (c *Client) createChatCompletionStream(ctx context.Context, req ChatCompletionRequest, onDelta func(delta string)) (*ChatCompletionResponse, error) {
+	req.Stream = true
+	req.StreamOptions = &StreamOptions{IncludeUsage: true}
+
+	resp, err := c.send(ctx, c.streamClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			fmt.Printf("Error closing response body: %v\n", err)
+		}
+	}(resp.Body)
+
+	respData, err := readStream(resp.Body, onDelta)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("request cancelled: %w", ctx.Err())
+		}
+		if c.logger != nil {
+			c.logger.LogInteraction(req, nil, err)
+		}
+		return nil, err
+	}
+
+	// Log the interaction
+	if c.logger != nil {
+		c.logger.LogInteraction(req, respData, nil)
+	}
+
+	return respData, nil
+}
+
+// readStream reads server-sent events from r and assembles them into a response
+func readStream(r io.Reader, onDelta func(delta string)) (*ChatCompletionResponse, error) {
+	acc := newStreamAccumulator()
+	reader := bufio.NewReader(r)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("reading stream: %w", err)
+		}
+
+		line = strings.TrimSpace(line)
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				break
+			}
+
+			var chunk ChatCompletionChunk
+			if jsonErr := json.Unmarshal([]byte(data), &chunk); jsonErr != nil {
+				return nil, fmt.Errorf("unmarshaling stream chunk: %w", jsonErr)
+			}
+			acc.add(chunk, onDelta)
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return acc.response(), nil
+}
+
+// streamAccumulator assembles stream chunks into a complete response
+type streamAccumulator struct {
+	resp    ChatCompletionResponse
+	choices map[int]*choiceAccumulator
+	order   []int
+}
+
+type choiceAccumulator struct {
+	role         string
+	content      strings.Builder
+	toolCalls    map[int]*ToolCall
+	toolOrder    []int
+	finishReason string
+}
+
+func newStreamAccumulator() *streamAccumulator {
+	return &streamAccumulator{
+		choices: make(map[int]*choiceAccumulator),
+	}
+}
+
+func (a *streamAccumulator) add(chunk ChatCompletionChunk, onDelta func(delta string)) {
+	if chunk.ID != "" {
+		a.resp.ID = chunk.ID
+	}
+	if chunk.Created != 0 {
+		a.resp.Created = chunk.Created
+	}
+	if chunk.Usage != nil {
+		a.resp.Usage = *chunk.Usage
+	}
+
+	for _, c := range chunk.Choices {
+		choice, ok := a.choices[c.Index]
+		if !ok {
+			choice = &choiceAccumulator{toolCalls: make(map[int]*ToolCall)}
+			a.choices[c.Index] = choice
+			a.order = append(a.order, c.Index)
+		}
+
+		if c.Delta.Role != "" {
+			choice.role = c.Delta.Role
+		}
+		if c.Delta.Content != "" {
+			choice.content.WriteString(c.Delta.Content)
+			if onDelta != nil && c.Index == 0 {
+				onDelta(c.Delta.Content)
+			}
+		}
+
+		for _, td := range c.Delta.ToolCalls {
+			call, ok := choice.toolCalls[td.Index]
+			if !ok {
+				call = &ToolCall{Type: "function"}
+				choice.toolCalls[td.Index] = call
+				choice.toolOrder = append(choice.toolOrder, td.Index)
+			}
+			if td.ID != "" {
+				call.ID = td.ID
+			}
+			if td.Type != "" {
+				call.Type = td.Type
+			}
+			call.Function.Name += td.Function.Name
+			call.Function.Arguments += td.Function.Arguments
+		}
+
+		if c.FinishReason != nil {
+			choice.finishReason = *c.FinishReason
+		}
+	}
+}
+
+func (a *streamAccumulator) response() *ChatCompletionResponse {
+	resp := a.resp
+	resp.Object = "chat.completion"
+
+	for _, index := range a.order {
+		choice := a.choices[index]
+
+		role := choice.role
+		if role == "" {
+			role = "assistant"
+		}
+
+		message := Message{
+			Role:    role,
+			Content: choice.content.String(),
+		}
+		for _, i := range choice.toolOrder {
+			message.ToolCalls = append(message.ToolCalls, *choice.toolCalls[i])
+		}
+
+		resp.Choices = append(resp.Choices, ChatCompletionChoice{
+			Index:        index,
+			Message:      message,
+			FinishReason: choice.finishReason,
+		})
+	}
+
+	return &resp
+}
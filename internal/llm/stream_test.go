@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreateChatCompletionStream(t *testing.T) {
+	events := []string{
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"role":"assistant","content":"Let me "}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"check."}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read","arguments":""}}]}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":"}}]}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"main.go\"}"}}]}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"ls","arguments":"{\"path\":\".\"}"}}]}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"id":"chatcmpl-1","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", nil)
+
+	var deltas []string
+	resp, err := client.CreateChatCompletionStream(context.Background(), ChatCompletionRequest{Model: "test"}, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := strings.Join(deltas, ""); got != "Let me check." {
+		t.Errorf("expected streamed text %q, got %q", "Let me check.", got)
+	}
+
+	want := Message{
+		Role:    "assistant",
+		Content: "Let me check.",
+		ToolCalls: []ToolCall{
+			{ID: "call_1", Type: "function", Function: FunctionCall{Name: "read", Arguments: `{"path":"main.go"}`}},
+			{ID: "call_2", Type: "function", Function: FunctionCall{Name: "ls", Arguments: `{"path":"."}`}},
+		},
+	}
+
+	if len(resp.Choices) != 1 {
+		t.Fatalf("expected 1 choice, got %d", len(resp.Choices))
+	}
+	if !reflect.DeepEqual(resp.Choices[0].Message, want) {
+		t.Errorf("expected message %+v, got %+v", want, resp.Choices[0].Message)
+	}
+	if resp.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("expected finish reason tool_calls, got %q", resp.Choices[0].FinishReason)
+	}
+	if resp.Usage.TotalTokens != 15 {
+		t.Errorf("expected 15 total tokens, got %d", resp.Usage.TotalTokens)
+	}
+}
@@ -0,0 +1,49 @@
+package llm
+
+// charsPerToken is the average number of characters per token of English text
+// and code with the tokenizers of current models
+const charsPerToken = 4
+
+// messageOverhead is the number of tokens the chat format adds to each message
+const messageOverhead = 4
+
+// EstimateTokens estimates the number of prompt tokens of messages. It is a
+// rough approximation for deciding when the context window is filling up,
+// not an exact count.
+func EstimateTokens(messages []Message) int {
+	tokens := 0
+	for _, msg := range messages {
+		tokens += EstimateMessageTokens(msg)
+	}
+	return tokens
+}
+
+// EstimateMessageTokens estimates the number of tokens of a single message
+func EstimateMessageTokens(msg Message) int {
+	chars := len(msg.Role) + len(msg.Content)
+	for _, toolCall := range msg.ToolCalls {
+		chars += len(toolCall.ID) + len(toolCall.Function.Name) + len(toolCall.Function.Arguments)
+	}
+	return messageOverhead + (chars+charsPerToken-1)/charsPerToken
+}
+
+// TurnStart returns the index of the message that starts the last n turns of
+// messages. A turn starts with a user message and includes the assistant's
+// replies and tool calls that follow it. It returns 0 if there are fewer than
+// n turns.
+func TurnStart(messages []Message, n int) int {
+	if n <= 0 {
+		return len(messages)
+	}
+
+	turns := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			turns++
+			if turns == n {
+				return i
+			}
+		}
+	}
+	return 0
+}
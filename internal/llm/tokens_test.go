@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTurnStart(t *testing.T) {
+	messages := []Message{
+		{Role: "system", Content: "prompt"},
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "", ToolCalls: []ToolCall{{ID: "call_1"}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "result"},
+		{Role: "assistant", Content: "done"},
+		{Role: "user", Content: "second"},
+		{Role: "assistant", Content: "ok"},
+	}
+
+	tests := []struct {
+		turns int
+		want  int
+	}{
+		{turns: 1, want: 5},
+		{turns: 2, want: 1},
+		{turns: 3, want: 0},
+		{turns: 0, want: len(messages)},
+	}
+
+	for _, tt := range tests {
+		if got := TurnStart(messages, tt.turns); got != tt.want {
+			t.Errorf("TurnStart(%d) = %d, want %d", tt.turns, got, tt.want)
+		}
+	}
+}
+
+func TestAgentContextTokens(t *testing.T) {
+	agent := NewAgent("Coder", "prompt", nil, ModelConfig{}, nil, nil, nil)
+	agent.AddMessage("user", strings.Repeat("a", 400))
+
+	if got, want := agent.ContextTokens(), EstimateTokens(agent.Messages); got != want {
+		t.Errorf("expected an estimate before any request, got %d want %d", got, want)
+	}
+
+	// Pretend a request was sent with these messages and answered
+	agent.lastUsage = Usage{PromptTokens: 1000, CompletionTokens: 50}
+	agent.lastMessages = len(agent.Messages)
+	agent.AddMessage("assistant", "reply")
+	agent.AddMessage("user", strings.Repeat("b", 40))
+
+	if got, want := agent.ContextTokens(), 1050+EstimateMessageTokens(agent.Messages[3]); got != want {
+		t.Errorf("expected reported usage plus new messages, got %d want %d", got, want)
+	}
+
+	agent.CompactMessages(3, Message{Role: "user", Content: "summary"})
+	if len(agent.Messages) != 3 || agent.Messages[1].Content != "summary" || agent.Messages[2].Content != strings.Repeat("b", 40) {
+		t.Fatalf("unexpected messages after compacting: %+v", agent.Messages)
+	}
+	if got, want := agent.ContextTokens(), EstimateTokens(agent.Messages); got != want {
+		t.Errorf("expected an estimate after compacting, got %d want %d", got, want)
+	}
+}
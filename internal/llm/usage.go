@@ -0,0 +1,143 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ModelPrice is the price of a model in dollars per million tokens
+type ModelPrice struct {
+	Input  float64
+	Output float64
+}
+
+// UsageRecord is the token usage of a single request
+type UsageRecord struct {
+	Agent string
+	Model string
+	Usage Usage
+}
+
+// UsageTotal sums the usage of several requests
+type UsageTotal struct {
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	// Cost in dollars of the requests with a known price
+	Cost float64
+	// Unpriced is the number of requests to models without a known price
+	Unpriced int
+}
+
+// UsageSummary breaks down the usage of a session
+type UsageSummary struct {
+	ByAgent map[string]UsageTotal
+	ByModel map[string]UsageTotal
+	Total   UsageTotal
+	// Budget is the session budget in dollars, 0 if there is none
+	Budget float64
+}
+
+// UsageTracker records the token usage of every request made in a session
+// and prices it. It is safe for concurrent use by several agents.
+type UsageTracker struct {
+	mu      sync.Mutex
+	prices  map[string]ModelPrice
+	budget  float64
+	records []UsageRecord
+}
+
+// NewUsageTracker creates a tracker with the given prices keyed by model name.
+// A model is priced by the longest key that is a prefix of its name, so that
+// "gpt-4o" also covers dated snapshots such as "gpt-4o-2024-08-06". A budget
+// above zero caps the cost of the session.
+func NewUsageTracker(prices map[string]ModelPrice, budget float64) *UsageTracker {
+	return &UsageTracker{
+		prices: prices,
+		budget: budget,
+	}
+}
+
+// Record adds the usage of a request made by agent
+func (t *UsageTracker) Record(agent, model string, usage Usage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records = append(t.records, UsageRecord{Agent: agent, Model: model, Usage: usage})
+}
+
+// Records returns the usage of every request so far
+func (t *UsageTracker) Records() []UsageRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	records := make([]UsageRecord, len(t.records))
+	copy(records, t.records)
+	return records
+}
+
+// Price returns the price of model, and false if it is unknown
+func (t *UsageTracker) Price(model string) (ModelPrice, bool) {
+	model = strings.ToLower(model)
+
+	best := ""
+	for name := range t.prices {
+		if strings.HasPrefix(model, strings.ToLower(name)) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return t.prices[best], true
+}
+
+// Summary totals the usage by agent, by model and for the whole session
+func (t *UsageTracker) Summary() UsageSummary {
+	summary := UsageSummary{
+		ByAgent: make(map[string]UsageTotal),
+		ByModel: make(map[string]UsageTotal),
+		Budget:  t.budget,
+	}
+
+	for _, record := range t.Records() {
+		total := UsageTotal{
+			Requests:         1,
+			PromptTokens:     record.Usage.PromptTokens,
+			CompletionTokens: record.Usage.CompletionTokens,
+		}
+		if price, ok := t.Price(record.Model); ok {
+			total.Cost = (float64(record.Usage.PromptTokens)*price.Input +
+				float64(record.Usage.CompletionTokens)*price.Output) / 1_000_000
+		} else {
+			total.Unpriced = 1
+		}
+
+		summary.ByAgent[record.Agent] = summary.ByAgent[record.Agent].add(total)
+		summary.ByModel[record.Model] = summary.ByModel[record.Model].add(total)
+		summary.Total = summary.Total.add(total)
+	}
+
+	return summary
+}
+
+// CheckBudget returns an error once the session has cost more than its budget
+func (t *UsageTracker) CheckBudget() error {
+	if t.budget <= 0 {
+		return nil
+	}
+
+	if cost := t.Summary().Total.Cost; cost >= t.budget {
+		return fmt.Errorf("session budget of $%.2f exceeded ($%.2f spent)", t.budget, cost)
+	}
+	return nil
+}
+
+func (u UsageTotal) add(other UsageTotal) UsageTotal {
+	return UsageTotal{
+		Requests:         u.Requests + other.Requests,
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		Cost:             u.Cost + other.Cost,
+		Unpriced:         u.Unpriced + other.Unpriced,
+	}
+}
@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUsageTracker(t *testing.T) {
+	tracker := NewUsageTracker(map[string]ModelPrice{
+		"gpt-4o":      {Input: 2.50, Output: 10.00},
+		"gpt-4o-mini": {Input: 0.15, Output: 0.60},
+	}, 0.02)
+
+	tracker.Record("Coder", "gpt-4o-2024-08-06", Usage{PromptTokens: 1000, CompletionTokens: 500})
+	tracker.Record("CodeAnalysisAgent", "gpt-4o-mini", Usage{PromptTokens: 10000, CompletionTokens: 1000})
+	tracker.Record("Coder", "llama3", Usage{PromptTokens: 100, CompletionTokens: 10})
+
+	summary := tracker.Summary()
+
+	if got := summary.ByAgent["Coder"]; got.Requests != 2 || got.PromptTokens != 1100 || got.Unpriced != 1 {
+		t.Errorf("unexpected usage for Coder: %+v", got)
+	}
+	if got := summary.ByModel["gpt-4o-2024-08-06"].Cost; math.Abs(got-0.0075) > 1e-9 {
+		t.Errorf("expected dated snapshot to be priced as gpt-4o, got cost %f", got)
+	}
+	if got := summary.ByModel["gpt-4o-mini"].Cost; math.Abs(got-0.0021) > 1e-9 {
+		t.Errorf("expected longest prefix to win for gpt-4o-mini, got cost %f", got)
+	}
+	if summary.Total.Requests != 3 || summary.Total.Unpriced != 1 {
+		t.Errorf("unexpected session total: %+v", summary.Total)
+	}
+
+	if err := tracker.CheckBudget(); err != nil {
+		t.Errorf("expected budget not to be exceeded yet, got %v", err)
+	}
+
+	tracker.Record("Coder", "gpt-4o", Usage{PromptTokens: 8000})
+	if err := tracker.CheckBudget(); err == nil {
+		t.Error("expected budget to be exceeded")
+	}
+}
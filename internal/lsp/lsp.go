@@ -12,6 +12,7 @@ import (
 	"github.com/pterm/pterm"
 	"go.bug.st/lsp"
 
+	"github.com/recrsn/coder/internal/common"
 	"github.com/recrsn/coder/internal/platform"
 )
 
@@ -163,23 +164,8 @@ func (m *Manager) ensureServerRunning(filePath string) (string, error) {
 
 // findWorkspaceRoot finds the workspace root directory from a file path
 func findWorkspaceRoot(filePath string) (string, error) {
-	// Try to find Git repository root
-	dir := filepath.Dir(filePath)
-	for {
-		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
-			return dir, nil
-		}
-
-		parentDir := filepath.Dir(dir)
-		if parentDir == dir {
-			// We've reached the root directory
-			break
-		}
-		dir = parentDir
-	}
-
-	// Fall back to the file's directory
-	return filepath.Dir(filePath), nil
+	// The Git repository root, or the file's directory outside a repository
+	return common.FindProjectRoot(filepath.Dir(filePath)), nil
 }
 
 // startServer starts a language server
@@ -267,7 +253,7 @@ func (m *Manager) startServer(language, rootPath string) error {
 
 	if rpcErr != nil {
 		spinner.Fail(fmt.Sprintf("Failed to initialize %s language server: %v", language, rpcErr))
-		return fmt.Errorf("failed to initialize language server: %w", rpcErr)
+		return fmt.Errorf("failed to initialize language server: %v", rpcErr)
 	}
 
 	// Notify initialized
@@ -8,10 +8,15 @@ import (
 	"github.com/recrsn/coder/internal/schema"
 	"github.com/recrsn/coder/internal/ui"
 	"strings"
+	"sync"
+	"time"
 )
 
+// agentTimeout bounds how long a sub-agent may work on its task
+const agentTimeout = 15 * time.Minute
+
 // NewAgentTool creates a tool that launches an interactive agent with read-only tools
-func NewAgentTool(registry *Registry, client *llm.Client, userInterface ui.UserInterface, modelName string, permissionManager *common.PermissionManager) *Tool {
+func NewAgentTool(registry *Registry, client llm.Provider, userInterface ui.UserInterface, modelName string, permissionManager *common.PermissionManager, usage *llm.UsageTracker, parallelWorkers int) *Tool {
 	inputSchema := schema.Schema{
 		Type: "object",
 		Properties: map[string]schema.Property{
@@ -28,7 +33,8 @@ func NewAgentTool(registry *Registry, client *llm.Client, userInterface ui.UserI
 	}
 
 	return &Tool{
-		Name: "agent",
+		Name:    "agent",
+		Timeout: agentTimeout,
 		Description: "Launch a new agent that can analyze code by using read-only tools." +
 			" Agent cannot use any write tools or receive input from the user." +
 			" It can only send a response back to the caller.",
@@ -40,27 +46,16 @@ func NewAgentTool(registry *Registry, client *llm.Client, userInterface ui.UserI
 				Context: fmt.Sprintf("Launch an agent to perform task: %s", description),
 			}
 		},
-		Execute: func(input map[string]any) (string, error) {
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
 			prompt, _ := input["prompt"].(string)
 			description, _ := input["description"].(string)
 
-			// Create a filtered registry with only read-only tools
-			readOnlyTools := []string{
-				"read", "ls", "glob", "grep", "tree", "outline",
-				"lsp_definition", "lsp_references", "lsp_callhierarchy",
-			}
-
-			// Create a filter registry with only read-only tools
-			filteredRegistry := NewRegistry()
-			for _, name := range readOnlyTools {
-				tool, ok := registry.Get(name)
-				if ok {
-					filteredRegistry.Register(name, tool)
-				}
-			}
+			// The agent may only use read-only tools
+			filteredRegistry := registry.ReadOnly()
 
-			// Create an output builder
+			// Create an output builder, shared by tool calls running concurrently
 			var outputBuilder strings.Builder
+			var outputMu sync.Mutex
 			outputBuilder.WriteString(fmt.Sprintf("## Agent Task: %s\n\n", description))
 
 			// Build agent system prompt
@@ -91,52 +86,68 @@ Show your work by explaining how you arrived at your conclusions.`
 						// Continue processing
 					}
 
+					// Each call writes its own section, added to the output once it is done
+					var section strings.Builder
+					defer func() {
+						outputMu.Lock()
+						outputBuilder.WriteString(section.String())
+						outputMu.Unlock()
+					}()
+
 					// Get the tool from the registry
 					tool, ok := filteredRegistry.Get(toolName)
 					if !ok {
 						errorMsg := fmt.Sprintf("Tool not found: %s", toolName)
+						outputMu.Lock()
 						userInterface.PrintError(errorMsg)
+						outputMu.Unlock()
 
-						outputBuilder.WriteString(fmt.Sprintf("### Error: %s\n\n", errorMsg))
+						section.WriteString(fmt.Sprintf("### Error: %s\n\n", errorMsg))
 						return errorMsg, nil
 					}
 
 					var detail = tool.Explain(args)
+					command, paths := tool.PermissionSubjects(args)
 					request := common.PermissionRequest{
 						ToolName:  toolName,
 						Arguments: args,
 						Title:     detail.Title,
 						Context:   detail.Context,
+						Command:   command,
+						Paths:     paths,
+						ReadOnly:  !tool.Modifies(),
 					}
 
 					response := permissionManager.RequestPermission(request)
 					execute := response.Granted
 					alternate := response.AlternateAction
 
-					outputBuilder.WriteString(fmt.Sprintf("### Tool Call: %s\n", toolName))
-					outputBuilder.WriteString("```\n")
+					section.WriteString(fmt.Sprintf("### Tool Call: %s\n", toolName))
+					section.WriteString("```\n")
 					for k, v := range args {
-						outputBuilder.WriteString(fmt.Sprintf("%s: %v\n", k, v))
+						section.WriteString(fmt.Sprintf("%s: %v\n", k, v))
 					}
-					outputBuilder.WriteString("```\n\n")
+					section.WriteString("```\n\n")
 
 					// If denied, return alternate instructions
 					if !execute {
 						errorMsg := "Permission denied by user"
-						outputBuilder.WriteString(fmt.Sprintf("Error: %s\n\n", errorMsg))
+						section.WriteString(fmt.Sprintf("Error: %s\n\n", errorMsg))
 						return fmt.Sprintf("Tool use denied by user. %s", alternate), nil
 					}
 
 					// Execute the tool
-					result, err := tool.Run(args)
+					result, err := tool.Run(ctx, args)
 
 					// Display the result
+					outputMu.Lock()
 					userInterface.PrintToolCall(toolName, args, result, err)
+					outputMu.Unlock()
 
-					outputBuilder.WriteString("### Tool Result\n")
+					section.WriteString("### Tool Result\n")
 					if err != nil {
 						errorMsg := fmt.Sprintf("Error executing %s: %s", toolName, err.Error())
-						outputBuilder.WriteString(fmt.Sprintf("Error: %s\n\n", errorMsg))
+						section.WriteString(fmt.Sprintf("Error: %s\n\n", errorMsg))
 						return errorMsg, nil
 					}
 
@@ -145,9 +156,9 @@ Show your work by explaining how you arrived at your conclusions.`
 					if len(result) > 2000 {
 						resultOutput = result[:1997] + "..."
 					}
-					outputBuilder.WriteString("```\n")
-					outputBuilder.WriteString(resultOutput)
-					outputBuilder.WriteString("\n```\n\n")
+					section.WriteString("```\n")
+					section.WriteString(resultOutput)
+					section.WriteString("\n```\n\n")
 
 					return result, nil
 				},
@@ -161,11 +172,14 @@ Show your work by explaining how you arrived at your conclusions.`
 				},
 			)
 
+			// Count the sub-agent's tokens towards the session
+			agent.SetUsageTracker(usage)
+			agent.SetParallelTools(filteredRegistry.IsReadOnly, parallelWorkers)
+
 			// Add the user prompt
 			agent.AddMessage("user", prompt)
 
-			// Run the agent
-			ctx := context.Background()
+			// Run the agent until the caller's turn is interrupted
 			finalMessage, err := agent.Run(ctx)
 			if err != nil {
 				return fmt.Sprintf("Error running agent: %s", err.Error()), nil
@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/recrsn/coder/internal/schema"
+)
+
+// NewApplyPatchTool creates a tool to apply a patch to several files at once.
+// It only changes files as files recorded them.
+func NewApplyPatchTool(files *FileTracker) *Tool {
+	return &Tool{
+		Name: "apply_patch",
+		Description: "Apply a patch to one or more files. Accepts a unified diff (--- a/path, +++ b/path, @@ hunks) " +
+			"or an envelope of \"*** Begin Patch\", \"*** Update File: path\", \"*** Add File: path\" or " +
+			"\"*** Delete File: path\" sections with @@ hunks, and \"*** End Patch\". Hunks may be slightly off: " +
+			"they apply where their context matches closest. Either every file is changed or, if a hunk doesn't match, none.",
+		ExtraPaths: func(input map[string]any) []string {
+			text, _ := input["patch"].(string)
+			patches, err := parsePatch(text)
+			if err != nil {
+				return nil
+			}
+			paths := make([]string, len(patches))
+			for i, patch := range patches {
+				paths[i] = patch.Path
+			}
+			return paths
+		},
+		InputSchema: schema.Schema{
+			Type: "object",
+			Properties: map[string]schema.Property{
+				"patch": {
+					Type:        "string",
+					Description: "The patch to apply",
+				},
+			},
+			Required: []string{"patch"},
+		},
+		Explain: func(input map[string]any) ExplainResult {
+			text, _ := input["patch"].(string)
+			patches, err := parsePatch(text)
+			if err != nil {
+				return ExplainResult{
+					Title:   "ApplyPatch(invalid patch)",
+					Context: fmt.Sprintf("The patch can't be parsed: %v\n\n```diff\n%s\n```", err, text),
+				}
+			}
+
+			paths := make([]string, len(patches))
+			for i, patch := range patches {
+				paths[i] = patch.Path
+			}
+			return ExplainResult{
+				Title:   fmt.Sprintf("ApplyPatch(%s)", strings.Join(paths, ", ")),
+				Context: fmt.Sprintf("Will change %d files:\n\n```diff\n%s\n```", len(patches), strings.TrimRight(text, "\n")),
+			}
+		},
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
+			patches, err := parsePatch(input["patch"].(string))
+			if err != nil {
+				return "", fmt.Errorf("invalid patch: %w", err)
+			}
+
+			changes, summary, err := patchChanges(patches)
+			if err != nil {
+				return "", fmt.Errorf("%w\nNo file was changed. Read the file again and retry with a corrected patch.", err)
+			}
+			for _, change := range changes {
+				if err := files.CheckWrite(change.Path); err != nil {
+					return "", fmt.Errorf("%w\nNo file was changed.", err)
+				}
+			}
+			if err := WriteFiles(changes); err != nil {
+				return "", err
+			}
+			for _, change := range changes {
+				if !change.Delete {
+					files.Record(change.Path, change.Content)
+				}
+			}
+			return "Applied the patch:\n" + strings.Join(summary, "\n"), nil
+		},
+	}
+}
+
+// patchChanges applies patches in memory and returns the resulting changes
+// and a line per file describing it
+func patchChanges(patches []filePatch) ([]FileChange, []string, error) {
+	var changes []FileChange
+	var summary []string
+	// Later patches of the same file apply to the result of earlier ones
+	index := make(map[string]int)
+
+	for _, patch := range patches {
+		i, seen := index[patch.Path]
+		var content string
+		exists := false
+		if seen {
+			content, exists = string(changes[i].Content), !changes[i].Delete
+		} else {
+			data, err := os.ReadFile(patch.Path)
+			switch {
+			case err == nil:
+				content, exists = string(data), true
+			case !errors.Is(err, fs.ErrNotExist):
+				return nil, nil, err
+			}
+		}
+
+		change := FileChange{Path: patch.Path}
+		switch {
+		case patch.Delete:
+			if !exists {
+				return nil, nil, fmt.Errorf("can't delete %s, it doesn't exist", patch.Path)
+			}
+			change.Delete = true
+			summary = append(summary, "Deleted "+patch.Path)
+		case patch.Add && exists:
+			return nil, nil, fmt.Errorf("can't add %s, it already exists", patch.Path)
+		case !patch.Add && !exists:
+			return nil, nil, fmt.Errorf("can't update %s, it doesn't exist", patch.Path)
+		default:
+			updated, err := applyHunks(content, patch.Hunks)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", patch.Path, err)
+			}
+			change.Content = []byte(updated)
+			verb := "Updated "
+			if patch.Add {
+				verb = "Added "
+			}
+			summary = append(summary, fmt.Sprintf("%s%s (%d hunks)", verb, patch.Path, len(patch.Hunks)))
+		}
+
+		if seen {
+			changes[i] = change
+		} else {
+			index[patch.Path] = len(changes)
+			changes = append(changes, change)
+		}
+	}
+	return changes, summary, nil
+}
@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyHunks(t *testing.T) {
+	content := "package main\n\nfunc main() {\n\tfmt.Println(\"hello\")\n\treturn\n}\n"
+
+	tests := []struct {
+		name     string
+		patch    string
+		expected string
+		err      string
+	}{
+		{
+			name:     "exact",
+			patch:    "--- a/main.go\n+++ b/main.go\n@@ -3,3 +3,3 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"bye\")\n \treturn\n",
+			expected: "package main\n\nfunc main() {\n\tfmt.Println(\"bye\")\n\treturn\n}\n",
+		},
+		{
+			name:     "wrong line number",
+			patch:    "--- a/main.go\n+++ b/main.go\n@@ -40,2 +40,2 @@\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"bye\")\n \treturn\n",
+			expected: "package main\n\nfunc main() {\n\tfmt.Println(\"bye\")\n\treturn\n}\n",
+		},
+		{
+			name:     "different indentation",
+			patch:    "--- a/main.go\n+++ b/main.go\n@@ -3,3 +3,3 @@\n func main() {\n-    fmt.Println(\"hello\")\n+\tfmt.Println(\"bye\")\n     return\n",
+			expected: "package main\n\nfunc main() {\n\tfmt.Println(\"bye\")\n\treturn\n}\n",
+		},
+		{
+			name:     "stale context is fuzzed",
+			patch:    "--- a/main.go\n+++ b/main.go\n@@ -3,3 +3,3 @@\n func main() { // old\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"bye\")\n \treturn\n",
+			expected: "package main\n\nfunc main() {\n\tfmt.Println(\"bye\")\n\treturn\n}\n",
+		},
+		{
+			name:     "insertion",
+			patch:    "--- a/main.go\n+++ b/main.go\n@@ -1,0 +2,1 @@\n+// Package main\n",
+			expected: "package main\n// Package main\n\nfunc main() {\n\tfmt.Println(\"hello\")\n\treturn\n}\n",
+		},
+		{
+			name:     "envelope with two hunks",
+			patch:    "*** Begin Patch\n*** Update File: main.go\n@@\n-package main\n+package app\n@@ func main() {\n-\treturn\n+\tos.Exit(0)\n*** End Patch\n",
+			expected: "package app\n\nfunc main() {\n\tfmt.Println(\"hello\")\n\tos.Exit(0)\n}\n",
+		},
+		{
+			name:  "more lines than counted",
+			patch: "--- a/main.go\n+++ b/main.go\n@@ -4,1 +4,1 @@\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"bye\")\n+\tfmt.Println(\"again\")\n",
+			err:   "the hunk has more lines than its header",
+		},
+		{
+			name:  "context isn't fuzzed away",
+			patch: "--- a/main.go\n+++ b/main.go\n@@ -2,2 +2,3 @@\n alpha\n+inserted\n beta\n",
+			err:   "hunk 1 doesn't match",
+		},
+		{
+			name:  "no match",
+			patch: "--- a/main.go\n+++ b/main.go\n@@ -3,2 +3,2 @@\n-\tfmt.Println(\"other\")\n+\tfmt.Println(\"bye\")\n",
+			err:   "hunk 1 doesn't match",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			patches, err := parsePatch(tc.patch)
+			var actual string
+			if err == nil {
+				actual, err = applyHunks(content, patches[0].Hunks)
+			}
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("Expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tc.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseUnifiedDiffCountsHunkLines(t *testing.T) {
+	// A removed "-- " line followed by an added "++ " line looks like a file header
+	patch := "--- a/query.sql\n+++ b/query.sql\n@@ -1,2 +1,2 @@\n--- old comment\n+++ new comment\n SELECT 1;\n"
+
+	patches, err := parsePatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 1 || len(patches[0].Hunks) != 1 {
+		t.Fatalf("Expected one file with one hunk, got %+v", patches)
+	}
+	expected := []string{"--- old comment", "+++ new comment", " SELECT 1;"}
+	if lines := patches[0].Hunks[0].Lines; strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected the lines %q, got %q", expected, lines)
+	}
+}
+
+func TestApplyPatchTool(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	files := map[string]string{"a.txt": "one\ntwo\n", "b.txt": "three\n", "c.txt": "gone\n"}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tool := NewApplyPatchTool(nil)
+
+	// The second hunk fails, so nothing changes
+	failing := "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n-one\n+ONE\n two\n--- a/b.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-missing\n+THREE\n"
+	if _, err := tool.Run(context.Background(), map[string]any{"patch": failing}); err == nil || !strings.Contains(err.Error(), "No file was changed") {
+		t.Fatalf("Expected the patch to fail, got %v", err)
+	}
+	if content, _ := os.ReadFile("a.txt"); string(content) != files["a.txt"] {
+		t.Errorf("Expected a.txt to be unchanged, got %q", content)
+	}
+
+	patch := "*** Begin Patch\n*** Update File: a.txt\n-one\n+ONE\n two\n*** Add File: dir/new.txt\n+new\n\n+file\n*** Delete File: c.txt\n*** End Patch\n"
+	if command, paths := tool.PermissionSubjects(map[string]any{"patch": patch}); command != "" || strings.Join(paths, ",") != "a.txt,dir/new.txt,c.txt" {
+		t.Errorf("Expected the paths of the patch, got %v", paths)
+	}
+	result, err := tool.Run(context.Background(), map[string]any{"patch": patch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "Added dir/new.txt") {
+		t.Errorf("Unexpected result: %s", result)
+	}
+
+	expected := map[string]string{"a.txt": "ONE\ntwo\n", "b.txt": "three\n", "dir/new.txt": "new\n\nfile\n"}
+	for name, content := range expected {
+		actual, err := os.ReadFile(filepath.FromSlash(name))
+		if err != nil || string(actual) != content {
+			t.Errorf("Expected %s to be %q, got %q (%v)", name, content, actual, err)
+		}
+	}
+	if _, err := os.Stat("c.txt"); !os.IsNotExist(err) {
+		t.Errorf("Expected c.txt to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/recrsn/coder/internal/common"
+)
+
+// FileTracker remembers the files a session read or wrote, so that file
+// tools don't overwrite changes made by someone else in the meantime. A nil
+// FileTracker tracks nothing and allows every write.
+type FileTracker struct {
+	mu sync.Mutex
+	// files are the states of the files by resolved path
+	files map[string]fileState
+}
+
+// fileState is the state of a file when it was last read or written
+type fileState struct {
+	hash    [sha256.Size]byte
+	modTime time.Time
+	size    int64
+}
+
+// NewFileTracker creates an empty FileTracker
+func NewFileTracker() *FileTracker {
+	return &FileTracker{files: make(map[string]fileState)}
+}
+
+// Record remembers content as the state of path after the session read or
+// wrote it
+func (t *FileTracker) Record(path string, content []byte) {
+	if t == nil {
+		return
+	}
+	key, err := common.ResolvePath(path, "")
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(key)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.files[key] = fileState{hash: sha256.Sum256(content), modTime: info.ModTime(), size: info.Size()}
+}
+
+// CheckWrite returns an error if path exists but the session never read it,
+// or if it changed since the session last read or wrote it. New files may
+// always be written.
+func (t *FileTracker) CheckWrite(path string) error {
+	if t == nil {
+		return nil
+	}
+	key, err := common.ResolvePath(path, "")
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	state, ok := t.files[key]
+	t.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%s exists but wasn't read in this session; read it before changing it", path)
+	}
+	if info.ModTime().Equal(state.modTime) && info.Size() == state.size {
+		return nil
+	}
+
+	// The file was touched, but its content may be the same
+	content, err := os.ReadFile(key)
+	if err != nil {
+		return err
+	}
+	if sha256.Sum256(content) != state.hash {
+		return fmt.Errorf("%s: file changed on disk since last read; re-read it before changing it", path)
+	}
+	t.Record(path, content)
+	return nil
+}
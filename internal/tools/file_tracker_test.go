@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileTrackerStaleWrites(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+	unread := filepath.Join(dir, "unread.txt")
+	if err := os.WriteFile(unread, []byte("unread\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := NewFileTracker()
+	read, write := NewReadTool(files), NewWriteTool(files)
+	ctx := context.Background()
+
+	setFile := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	earlier := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		path   string
+		before func()
+		err    string
+	}{
+		{"new file", path, func() {}, ""},
+		{"written by the session", path, func() {}, ""},
+		{"never read", unread, func() {}, "wasn't read in this session"},
+		{"touched but unchanged", path, func() {
+			content, _ := os.ReadFile(path)
+			setFile(string(content), time.Now().Add(time.Minute))
+		}, ""},
+		{"changed on disk", path, func() { setFile("changed in an editor\n", earlier) }, "file changed on disk since last read"},
+		{"read again", path, func() { read.Run(ctx, map[string]any{"path": path}) }, ""},
+		{"read", unread, func() { read.Run(ctx, map[string]any{"path": unread}) }, ""},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.before()
+			_, err := write.Run(ctx, map[string]any{"path": tc.path, "content": strings.Repeat("x", i) + "\n"})
+			if tc.err == "" && err != nil {
+				t.Fatalf("Expected the write to succeed, got %v", err)
+			}
+			if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Fatalf("Expected error %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
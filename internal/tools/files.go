@@ -0,0 +1,213 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/recrsn/coder/internal/diff"
+)
+
+// FileChange is the new content of a file, or its deletion
+type FileChange struct {
+	Path    string
+	Content []byte
+	Delete  bool
+	// Mode is the new mode of the file, 0 to keep its mode or use 0644 for a
+	// new file
+	Mode fs.FileMode
+}
+
+// mode returns the mode the change gives the file of original
+func (c FileChange) mode(original originalFile) fs.FileMode {
+	switch {
+	case c.Mode != 0:
+		return c.Mode
+	case original.exists:
+		return original.info.Mode().Perm()
+	}
+	return 0644
+}
+
+// originalFile is the state of a file before a change, to roll it back
+type originalFile struct {
+	exists  bool
+	content []byte
+	info    fs.FileInfo
+}
+
+// WriteFiles makes changes so that either every file changes or none does.
+// New contents are written to temporary files next to their files first and
+// then renamed over them, and renamed files are restored if a later rename
+// fails. Symlinks are followed, so the files they point to change, and files
+// with several hard links are overwritten in place to keep the links. A
+// changed file keeps its mode, unless the change sets one, and where
+// permitted its owner.
+func WriteFiles(changes []FileChange) error {
+	changes = slices.Clone(changes)
+	temps := make([]string, len(changes))
+	defer func() {
+		for _, temp := range temps {
+			if temp != "" {
+				os.Remove(temp)
+			}
+		}
+	}()
+
+	originals := make([]originalFile, len(changes))
+	for i, change := range changes {
+		if !change.Delete {
+			path, err := resolveSymlinks(change.Path)
+			if err != nil {
+				return err
+			}
+			changes[i].Path = path
+		}
+		original, err := readOriginal(changes[i].Path)
+		if err != nil {
+			return err
+		}
+		originals[i] = original
+		if change.Delete || (original.exists && hardLinked(original.info)) {
+			continue
+		}
+
+		if temps[i], err = writeTemp(changes[i].Path, change.Content, change.mode(original), original); err != nil {
+			return err
+		}
+	}
+
+	for i, change := range changes {
+		var err error
+		switch {
+		case change.Delete:
+			err = os.Remove(change.Path)
+		case temps[i] == "":
+			// A hard linked file
+			err = os.WriteFile(change.Path, change.Content, change.mode(originals[i]))
+			if err == nil && change.Mode != 0 {
+				err = os.Chmod(change.Path, change.Mode)
+			}
+		default:
+			err = os.Rename(temps[i], change.Path)
+			temps[i] = ""
+		}
+		if err != nil {
+			if restoreErr := restoreFiles(changes[:i+1], originals); restoreErr != nil {
+				return fmt.Errorf("writing %s: %w\nRestoring the files changed before failed, "+
+					"so some files may be changed: %v", change.Path, err, restoreErr)
+			}
+			return fmt.Errorf("writing %s, no file was changed: %w", change.Path, err)
+		}
+	}
+	return nil
+}
+
+// resolveSymlinks returns path with its symlinks resolved, or path if it
+// doesn't exist yet
+func resolveSymlinks(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return path, nil
+	}
+	return resolved, err
+}
+
+func readOriginal(path string) (originalFile, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return originalFile{}, nil
+	}
+	if err != nil {
+		return originalFile{}, err
+	}
+	if info.IsDir() {
+		return originalFile{}, fmt.Errorf("%s is a directory", path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return originalFile{}, err
+	}
+	return originalFile{exists: true, content: content, info: info}, nil
+}
+
+// writeTemp writes content to a new temporary file next to path, with mode
+// and the owner of original if it exists, and returns its name
+func writeTemp(path string, content []byte, mode fs.FileMode, original originalFile) (string, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	file, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if original.exists {
+		// Only root may give a file away, so this fails for files of others
+		copyOwner(file.Name(), original.info)
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), mode)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("writing %s: %w", path, err)
+	}
+	return file.Name(), nil
+}
+
+// restoreFiles rolls changes back to the originals, as far as possible, and
+// returns the errors of the files it couldn't restore
+func restoreFiles(changes []FileChange, originals []originalFile) error {
+	var errs []error
+	for i, change := range changes {
+		var err error
+		if originals[i].exists {
+			err = os.WriteFile(change.Path, originals[i].content, originals[i].info.Mode().Perm())
+		} else {
+			err = os.Remove(change.Path)
+			if errors.Is(err, fs.ErrNotExist) {
+				err = nil
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// explainDiff describes the change of path from oldText to newText as a
+// unified diff in a code block, for permission requests
+func explainDiff(path, oldText, newText string) string {
+	name := diffName(path)
+	unified := diff.Unified("a/"+name, "b/"+name, oldText, newText)
+	if unified == "" {
+		return "The content doesn't change."
+	}
+	return "```diff\n" + strings.TrimSuffix(unified, "\n") + "\n```"
+}
+
+// diffName returns the name of path in the headers of a diff: relative to the
+// working directory inside it, like git, and without the leading separator
+// outside it
+func diffName(path string) string {
+	if !filepath.IsAbs(path) {
+		return filepath.ToSlash(filepath.Clean(path))
+	}
+	if workingDir, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(workingDir, path); err == nil && rel != ".." &&
+			!strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFilesKeepsLinksAndMode(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.sh")
+	if err := os.WriteFile(target, []byte("old\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	symlink := filepath.Join(dir, "symlink.sh")
+	if err := os.Symlink(target, symlink); err != nil {
+		t.Fatal(err)
+	}
+	hardLink := filepath.Join(dir, "hardlink.sh")
+	if err := os.Link(target, hardLink); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFiles([]FileChange{{Path: symlink, Content: []byte("new\n")}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info, err := os.Lstat(symlink); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected %s to stay a symlink", symlink)
+	}
+	for _, path := range []string{target, hardLink} {
+		content, err := os.ReadFile(path)
+		if err != nil || string(content) != "new\n" {
+			t.Errorf("Expected %s to contain the new content, got %q", path, content)
+		}
+	}
+	if info, err := os.Stat(target); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("Expected the mode to stay 0755, got %v", info.Mode().Perm())
+	}
+}
+
+func TestWriteFilesRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	if err := os.WriteFile(first, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Deleting a file that doesn't exist fails after first was written
+	missing := filepath.Join(dir, "missing.txt")
+
+	err := WriteFiles([]FileChange{{Path: first, Content: []byte("new\n")}, {Path: missing, Delete: true}})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if content, _ := os.ReadFile(first); string(content) != "old\n" {
+		t.Errorf("Expected %s to be restored, got %q", first, content)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package tools
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// hardLinked reports whether the file of info has other hard links
+func hardLinked(info fs.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Nlink > 1
+}
+
+// copyOwner gives path the owner and group of the file of info
+func copyOwner(path string, info fs.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return os.Chown(path, int(stat.Uid), int(stat.Gid))
+}
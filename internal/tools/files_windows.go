@@ -0,0 +1,16 @@
+//go:build windows
+
+package tools
+
+import "io/fs"
+
+// hardLinked reports whether the file of info has other hard links. The link
+// count isn't known on Windows.
+func hardLinked(info fs.FileInfo) bool {
+	return false
+}
+
+// copyOwner does nothing, Windows files have no Unix owner
+func copyOwner(path string, info fs.FileInfo) error {
+	return nil
+}
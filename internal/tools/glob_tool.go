@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"github.com/recrsn/coder/internal/schema"
 	"io/fs"
@@ -13,6 +14,17 @@ func NewGlobTool() *Tool {
 	return &Tool{
 		Name:        "glob",
 		Description: "Find files matching a glob pattern",
+		PathArgs:    []string{"root"},
+		// The pattern may lead out of the root, as with "../*"
+		ExtraPaths: func(input map[string]any) []string {
+			pattern, _ := input["pattern"].(string)
+			root, ok := input["root"].(string)
+			if !ok {
+				root = "."
+			}
+			return []string{globDir(root, pattern)}
+		},
+		ReadOnly: true,
 		InputSchema: schema.Schema{
 			Type: "object",
 			Properties: map[string]schema.Property{
@@ -39,13 +51,19 @@ func NewGlobTool() *Tool {
 				Context: fmt.Sprintf("Will search for files matching the pattern '%s' in directory '%s'", pattern, root),
 			}
 		},
-		Execute: func(input map[string]any) (string, error) {
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
 			pattern := input["pattern"].(string)
 			root, ok := input["root"].(string)
 			if !ok {
 				root = "."
 			}
 
+			// Only the directory before the first wildcard is checked for
+			// permission, so the pattern must not leave it afterwards
+			if parentAfterWildcard(pattern) {
+				return "", fmt.Errorf("the pattern can't contain .. after a wildcard, put the directory in root instead")
+			}
+
 			matches, err := filepath.Glob(filepath.Join(root, pattern))
 			if err != nil {
 				return "", err
@@ -61,6 +79,9 @@ func NewGlobTool() *Tool {
 						if err != nil {
 							return err
 						}
+						if err := ctx.Err(); err != nil {
+							return err
+						}
 
 						// Check if path matches the pattern
 						matched, err := filepath.Match(strings.Replace(pattern, "**", "*", -1), path)
@@ -96,3 +117,32 @@ func NewGlobTool() *Tool {
 		},
 	}
 }
+
+// globDir returns the directory a pattern searches below root: root joined
+// with the segments of pattern before the first one with a wildcard
+func globDir(root, pattern string) string {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	dir := root
+	for _, segment := range segments[:len(segments)-1] {
+		if strings.ContainsAny(segment, "*?[\\") {
+			break
+		}
+		dir = filepath.Join(dir, segment)
+	}
+	return dir
+}
+
+// parentAfterWildcard reports whether pattern has a .. segment after a
+// segment with a wildcard
+func parentAfterWildcard(pattern string) bool {
+	wildcard := false
+	for _, segment := range strings.Split(filepath.ToSlash(pattern), "/") {
+		switch {
+		case strings.ContainsAny(segment, "*?[\\"):
+			wildcard = true
+		case segment == ".." && wildcard:
+			return true
+		}
+	}
+	return false
+}
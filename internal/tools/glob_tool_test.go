@@ -0,0 +1,34 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGlobToolPermissionSubjects(t *testing.T) {
+	tests := []struct {
+		input    map[string]any
+		expected []string
+	}{
+		{map[string]any{"pattern": "*.go"}, []string{"."}},
+		{map[string]any{"pattern": "internal/*/*.go", "root": "src"}, []string{"src", "src/internal"}},
+		{map[string]any{"pattern": "../../etc/*"}, []string{"../../etc"}},
+		{map[string]any{"pattern": "../other/**/*.go", "root": "/work/project"}, []string{"/work/project", "/work/other"}},
+	}
+
+	tool := NewGlobTool()
+	for _, tt := range tests {
+		if _, paths := tool.PermissionSubjects(tt.input); !reflect.DeepEqual(paths, tt.expected) {
+			t.Errorf("%v: expected paths %v, got %v", tt.input, tt.expected, paths)
+		}
+	}
+}
+
+func TestGlobToolRejectsParentAfterWildcard(t *testing.T) {
+	_, err := NewGlobTool().Run(context.Background(), map[string]any{"pattern": "*/../../*"})
+	if err == nil || !strings.Contains(err.Error(), "..") {
+		t.Errorf("Expected the pattern to be rejected, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"github.com/recrsn/coder/internal/schema"
 	"io/fs"
@@ -15,6 +16,8 @@ func NewGrepTool() *Tool {
 	return &Tool{
 		Name:        "grep",
 		Description: "Search for patterns in files",
+		PathArgs:    []string{"paths"},
+		ReadOnly:    true,
 		InputSchema: schema.Schema{
 			Type: "object",
 			Properties: map[string]schema.Property{
@@ -60,7 +63,7 @@ func NewGrepTool() *Tool {
 					pattern, len(paths), recursiveText, strings.Join(paths, ", ")),
 			}
 		},
-		Execute: func(input map[string]any) (string, error) {
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
 			pattern := input["pattern"].(string)
 			pathsAny := input["paths"].([]interface{})
 			recursive, ok := input["recursive"].(bool)
@@ -92,6 +95,9 @@ func NewGrepTool() *Tool {
 							if err != nil {
 								return err
 							}
+							if err := ctx.Err(); err != nil {
+								return err
+							}
 
 							if !info.IsDir() {
 								fileMatches := searchFile(filePath, regex)
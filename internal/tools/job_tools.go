@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/recrsn/coder/internal/schema"
+)
+
+// jobIDSchema is the input of the tools that take a job id
+var jobIDSchema = schema.Schema{
+	Type: "object",
+	Properties: map[string]schema.Property{
+		"id": {
+			Type:        "integer",
+			Description: "The id of the job, as returned by shell with background",
+		},
+	},
+	Required: []string{"id"},
+}
+
+// NewJobOutputTool creates a tool to read the output of a background job
+func NewJobOutputTool(shell *Shell) *Tool {
+	return &Tool{
+		Name:        "job_output",
+		Description: "Read the output a background job wrote since the last time it was read, and whether it still runs",
+		// Not ReadOnly: reading consumes the output, so calls mustn't run
+		// concurrently or from subagents
+		InputSchema: jobIDSchema,
+		Explain: func(input map[string]any) ExplainResult {
+			id, _ := input["id"].(float64)
+			return ExplainResult{
+				Title:   fmt.Sprintf("JobOutput(%d)", int(id)),
+				Context: fmt.Sprintf("Will read the new output of job %d", int(id)),
+			}
+		},
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
+			id, _ := input["id"].(float64)
+			job, output, err := shell.JobOutput(int(id))
+			if err != nil {
+				return "", err
+			}
+
+			result := fmt.Sprintf("Job %d: %s\nStatus: %s\n", job.ID, job.Command, job.Status())
+			if output == "" {
+				return result + "\nNo new output\n", nil
+			}
+			return result + "\nNew output:\n" + output + "\n", nil
+		},
+	}
+}
+
+// NewJobsTool creates a tool to list the background jobs
+func NewJobsTool(shell *Shell) *Tool {
+	return &Tool{
+		Name:        "jobs",
+		Description: "List the background jobs started with shell and whether they still run",
+		ReadOnly:    true,
+		InputSchema: schema.Schema{
+			Type:       "object",
+			Properties: map[string]schema.Property{},
+		},
+		Explain: func(input map[string]any) ExplainResult {
+			return ExplainResult{
+				Title:   "Jobs()",
+				Context: "Will list the background jobs",
+			}
+		},
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
+			return shell.DescribeJobs(), nil
+		},
+	}
+}
+
+// NewJobKillTool creates a tool to kill a background job
+func NewJobKillTool(shell *Shell) *Tool {
+	return &Tool{
+		Name:        "job_kill",
+		Description: "Kill a background job and the processes it started",
+		InputSchema: jobIDSchema,
+		Explain: func(input map[string]any) ExplainResult {
+			id, _ := input["id"].(float64)
+			command := ""
+			if job, err := shell.Job(int(id)); err == nil {
+				command = job.Command
+			}
+			return ExplainResult{
+				Title:   fmt.Sprintf("JobKill(%d)", int(id)),
+				Context: fmt.Sprintf("Will kill job %d: %s", int(id), command),
+			}
+		},
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
+			id, _ := input["id"].(float64)
+			job, err := shell.KillJob(int(id))
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Job %d: %s\nStatus: %s\n", job.ID, job.Command, job.Status()), nil
+		},
+	}
+}
@@ -0,0 +1,223 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jobKillTimeout is how long KillJob waits for a job to exit
+const jobKillTimeout = 5 * time.Second
+
+// Job is a command running in the background
+type Job struct {
+	ID      int
+	Command string
+	Started time.Time
+
+	stop context.CancelFunc
+	// done is closed when the job exited, with exitCode
+	done     chan struct{}
+	exitCode int
+
+	// mu guards output, the output since the last poll
+	mu     sync.Mutex
+	output *commandOutput
+}
+
+// Running reports whether the job hasn't exited yet
+func (j *Job) Running() bool {
+	select {
+	case <-j.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Status describes whether the job runs or how it exited
+func (j *Job) Status() string {
+	switch {
+	case j.Running():
+		return fmt.Sprintf("running for %s", time.Since(j.Started).Round(time.Second))
+	case j.exitCode < 0:
+		return "killed"
+	}
+	return fmt.Sprintf("exited with code %d", j.exitCode)
+}
+
+func (j *Job) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.output.Write(p)
+}
+
+// StartJob starts command in the background and returns its job. It runs in
+// the working directory and with the exported variables of the session's
+// shell.
+func (s *Shell) StartJob(ctx context.Context, command string) (*Job, error) {
+	output, err := newCommandOutput(s.options.OutputDir, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	argv := []string{"sh", "-c", command}
+	if state := s.state(ctx); state != "" {
+		// Variables the shell can't set again, e.g. read-only ones, are skipped quietly
+		argv = []string{"bash", "-c", "{\n" + state + "} 2>/dev/null\neval " + shellQuote(command)}
+	}
+
+	jobCtx, cancel := context.WithCancel(context.Background())
+	cmd, err := s.command(jobCtx, argv)
+	if err != nil {
+		cancel()
+		output.Close()
+		return nil, err
+	}
+	job := &Job{
+		Command: command,
+		Started: time.Now(),
+		stop:    cancel,
+		done:    make(chan struct{}),
+		output:  output,
+	}
+	cmd.Stdout = job
+	cmd.Stderr = job
+	if err := cmd.Start(); err != nil {
+		cancel()
+		output.Close()
+		return nil, fmt.Errorf("starting job: %w", err)
+	}
+
+	s.jobsMu.Lock()
+	s.nextJobID++
+	job.ID = s.nextJobID
+	s.jobs = append(s.jobs, job)
+	s.jobsMu.Unlock()
+
+	go func() {
+		cmd.Wait()
+		cancel()
+		job.exitCode = cmd.ProcessState.ExitCode()
+		close(job.done)
+		s.jobsChanged()
+	}()
+	s.jobsChanged()
+	return job, nil
+}
+
+// state returns a bash script that restores the working directory and the
+// exported variables of the bash process, or an empty string if there is
+// none
+func (s *Shell) state(ctx context.Context) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.process == nil || s.process.hasExited() {
+		return ""
+	}
+
+	var state bytes.Buffer
+	exitCode, exited, err := s.execute(ctx, `export -p; printf 'cd %q\n' "$PWD"`, &state)
+	if err != nil || exited || exitCode != 0 {
+		return ""
+	}
+	return state.String()
+}
+
+// Job returns the job with id
+func (s *Shell) Job(id int) (*Job, error) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	for _, job := range s.jobs {
+		if job.ID == id {
+			return job, nil
+		}
+	}
+	return nil, fmt.Errorf("no job with id %d", id)
+}
+
+// Jobs returns the jobs of the session, running or not
+func (s *Shell) Jobs() []*Job {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	return append([]*Job(nil), s.jobs...)
+}
+
+// RunningJobs returns how many jobs are running
+func (s *Shell) RunningJobs() int {
+	running := 0
+	for _, job := range s.Jobs() {
+		if job.Running() {
+			running++
+		}
+	}
+	return running
+}
+
+// JobOutput returns the output of the job with id since the last call, with
+// the head and the tail kept as for commands
+func (s *Shell) JobOutput(id int) (*Job, string, error) {
+	job, err := s.Job(id)
+	if err != nil {
+		return nil, "", err
+	}
+	next, err := newCommandOutput(s.options.OutputDir, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	job.mu.Lock()
+	output := job.output
+	job.output = next
+	job.mu.Unlock()
+
+	output.Close()
+	return job, output.String(), nil
+}
+
+// KillJob kills the job with id and its child processes
+func (s *Shell) KillJob(id int) (*Job, error) {
+	job, err := s.Job(id)
+	if err != nil {
+		return nil, err
+	}
+	job.stop()
+	select {
+	case <-job.done:
+	case <-time.After(jobKillTimeout):
+		return nil, fmt.Errorf("job %d didn't exit after it was killed", id)
+	}
+	return job, nil
+}
+
+// OnJobsChange sets a function called when a job starts or exits
+func (s *Shell) OnJobsChange(handler func()) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	s.onJobsChange = handler
+}
+
+func (s *Shell) jobsChanged() {
+	s.jobsMu.Lock()
+	handler := s.onJobsChange
+	s.jobsMu.Unlock()
+	if handler != nil {
+		handler()
+	}
+}
+
+// DescribeJobs lists the jobs of the session for the model or the user
+func (s *Shell) DescribeJobs() string {
+	jobs := s.Jobs()
+	if len(jobs) == 0 {
+		return "No background jobs"
+	}
+	var result strings.Builder
+	for _, job := range jobs {
+		fmt.Fprintf(&result, "%d: %s (%s)\n", job.ID, job.Command, job.Status())
+	}
+	return result.String()
+}
@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellJobs(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	dir := t.TempDir()
+	shell := NewShell(ShellOptions{})
+	t.Cleanup(shell.Close)
+	ctx := context.Background()
+
+	if _, err := shell.Run(ctx, "cd "+dir+" && export FOO=bar", false); err != nil {
+		t.Fatal(err)
+	}
+	result, err := NewShellTool(shell).Run(ctx, map[string]any{
+		"command":    "echo $FOO; pwd; while true; do echo tick; sleep 0.05; done",
+		"background": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "Started job 1") {
+		t.Fatalf("Expected job 1 to start, got %s", result)
+	}
+
+	var output string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline) && !strings.Contains(output, "tick"); {
+		time.Sleep(50 * time.Millisecond)
+		_, next, err := shell.JobOutput(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output += next
+	}
+	if !strings.HasPrefix(output, "bar\n"+dir+"\ntick\n") {
+		t.Errorf("Expected the job to run with the shell's variables and directory, got %q", output)
+	}
+	if running := shell.RunningJobs(); running != 1 {
+		t.Errorf("Expected 1 running job, got %d", running)
+	}
+
+	job, err := shell.KillJob(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Running() || job.Status() != "killed" {
+		t.Errorf("Expected the job to be killed, got %s", job.Status())
+	}
+	if jobs := shell.DescribeJobs(); !strings.Contains(jobs, "1: echo $FOO") || !strings.Contains(jobs, "(killed)") {
+		t.Errorf("Expected the killed job in the list, got %s", jobs)
+	}
+	if _, _, err := shell.JobOutput(2); err == nil {
+		t.Error("Expected an error for an unknown job")
+	}
+}
+
+func TestJobOutputToolInPlanMode(t *testing.T) {
+	tool := NewJobOutputTool(nil)
+	// Calls run one at a time and not from subagents, but plan mode allows them
+	if tool.ReadOnly || tool.Modifies() {
+		t.Errorf("Expected job_output not to be read-only nor to modify anything, got ReadOnly %v, Modifies %v",
+			tool.ReadOnly, tool.Modifies())
+	}
+}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"github.com/recrsn/coder/internal/schema"
 	"io/fs"
@@ -13,6 +14,8 @@ func NewLSTool() *Tool {
 	return &Tool{
 		Name:        "ls",
 		Description: "List files and directories",
+		PathArgs:    []string{"path"},
+		ReadOnly:    true,
 		InputSchema: schema.Schema{
 			Type: "object",
 			Properties: map[string]schema.Property{
@@ -45,7 +48,7 @@ func NewLSTool() *Tool {
 				Context: fmt.Sprintf("Will list files and directories in '%s'", path),
 			}
 		},
-		Execute: func(input map[string]any) (string, error) {
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
 			path := input["path"].(string)
 			recursive, ok := input["recursive"].(bool)
 			if !ok {
@@ -60,6 +63,9 @@ func NewLSTool() *Tool {
 					if err != nil {
 						return err
 					}
+					if err := ctx.Err(); err != nil {
+						return err
+					}
 					files = append(files, path)
 					return nil
 				})
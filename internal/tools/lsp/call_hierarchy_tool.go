@@ -1,6 +1,7 @@
 package lsp
 
 import (
+	"context"
 	"fmt"
 	"github.com/recrsn/coder/internal/lsp"
 	"path/filepath"
@@ -16,6 +17,8 @@ func NewCallHierarchyTool(manager *lsp.Manager) *tools.Tool {
 	return &tools.Tool{
 		Name:        "callhierarchy",
 		Description: "Explore function call hierarchies using the Language Server Protocol",
+		PathArgs:    []string{"file_path"},
+		ReadOnly:    true,
 		InputSchema: schema.Schema{
 			Type: "object",
 			Properties: map[string]schema.Property{
@@ -53,7 +56,7 @@ func NewCallHierarchyTool(manager *lsp.Manager) *tools.Tool {
 				Context: content,
 			}
 		},
-		Execute: func(input map[string]any) (string, error) {
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
 			// Extract parameters
 			filePath, _ := input["file_path"].(string)
 			line, _ := input["line"].(float64)
@@ -1,6 +1,7 @@
 package lsp
 
 import (
+	"context"
 	"fmt"
 	"github.com/recrsn/coder/internal/lsp"
 	"path/filepath"
@@ -15,6 +16,8 @@ func NewDefinitionTool(manager *lsp.Manager) *tools.Tool {
 	return &tools.Tool{
 		Name:        "definition",
 		Description: "Find the definition of a symbol using the Language Server Protocol",
+		PathArgs:    []string{"file_path"},
+		ReadOnly:    true,
 		InputSchema: schema.Schema{
 			Type: "object",
 			Properties: map[string]schema.Property{
@@ -47,7 +50,7 @@ func NewDefinitionTool(manager *lsp.Manager) *tools.Tool {
 				Context: content,
 			}
 		},
-		Execute: func(input map[string]any) (string, error) {
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
 			// Extract parameters
 			filePath, _ := input["file_path"].(string)
 			line, _ := input["line"].(float64)
@@ -1,6 +1,7 @@
 package lsp
 
 import (
+	"context"
 	"fmt"
 	"github.com/recrsn/coder/internal/lsp"
 	"path/filepath"
@@ -15,6 +16,8 @@ func NewReferencesTool(manager *lsp.Manager) *tools.Tool {
 	return &tools.Tool{
 		Name:        "references",
 		Description: "Find all references to a symbol using the Language Server Protocol",
+		PathArgs:    []string{"file_path"},
+		ReadOnly:    true,
 		InputSchema: schema.Schema{
 			Type: "object",
 			Properties: map[string]schema.Property{
@@ -61,7 +64,7 @@ func NewReferencesTool(manager *lsp.Manager) *tools.Tool {
 				Context: content,
 			}
 		},
-		Execute: func(input map[string]any) (string, error) {
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
 			// Extract parameters
 			filePath, _ := input["file_path"].(string)
 			line, _ := input["line"].(float64)
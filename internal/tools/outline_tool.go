@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"github.com/recrsn/coder/internal/tools/outline"
 	"os"
@@ -15,6 +16,8 @@ func NewOutlineTool() *Tool {
 	return &Tool{
 		Name:        "outline",
 		Description: "Generate an outline of symbols in a file (both public and private)",
+		PathArgs:    []string{"file"},
+		ReadOnly:    true,
 		InputSchema: schema.Schema{
 			Type: "object",
 			Properties: map[string]schema.Property{
@@ -36,7 +39,7 @@ func NewOutlineTool() *Tool {
 				Context: content,
 			}
 		},
-		Execute: func(input map[string]any) (string, error) {
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
 			filePath := input["file"].(string)
 
 			// Check if file exists
@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+const (
+	// outputHeadSize and outputTailSize are how many bytes of the start and
+	// the end of a command's output the model sees
+	outputHeadSize = 10 * 1024
+	outputTailSize = 20 * 1024
+)
+
+type outputKey struct{}
+
+// WithOutput returns a context that streams the output of a running tool to
+// show, e.g. to the UI while a command runs
+func WithOutput(ctx context.Context, show func(text string)) context.Context {
+	return context.WithValue(ctx, outputKey{}, show)
+}
+
+// outputFunc returns the function ctx streams output to, nil if there is none
+func outputFunc(ctx context.Context) func(text string) {
+	show, _ := ctx.Value(outputKey{}).(func(text string))
+	return show
+}
+
+// commandOutput collects the output of a command. It keeps the head and the
+// tail for the model, writes everything to a spill file so that the omitted
+// part can be read, and streams it as it is written.
+type commandOutput struct {
+	mu    sync.Mutex
+	head  []byte
+	tail  []byte
+	total int
+	spill *os.File
+	show  func(text string)
+}
+
+// newCommandOutput creates a commandOutput with a spill file in dir, or
+// without one if dir is empty
+func newCommandOutput(dir string, show func(text string)) (*commandOutput, error) {
+	output := &commandOutput{show: show}
+	if dir != "" {
+		spill, err := os.CreateTemp(dir, "output-*.txt")
+		if err != nil {
+			return nil, fmt.Errorf("creating output file: %w", err)
+		}
+		output.spill = spill
+	}
+	return output, nil
+}
+
+func (o *commandOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.total += len(p)
+	rest := p
+	if room := outputHeadSize - len(o.head); room > 0 {
+		n := min(room, len(rest))
+		o.head = append(o.head, rest[:n]...)
+		rest = rest[n:]
+	}
+	o.tail = append(o.tail, rest...)
+	if excess := len(o.tail) - outputTailSize; excess > 0 {
+		o.tail = append(o.tail[:0], o.tail[excess:]...)
+	}
+
+	if o.spill != nil {
+		// Losing the spill file only loses the omitted part
+		if _, err := o.spill.Write(p); err != nil {
+			o.spill.Close()
+			os.Remove(o.spill.Name())
+			o.spill = nil
+		}
+	}
+	if o.show != nil {
+		o.show(string(p))
+	}
+	return len(p), nil
+}
+
+// Close finishes the spill file, which is removed unless output was omitted
+func (o *commandOutput) Close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.spill == nil {
+		return
+	}
+	o.spill.Close()
+	if !o.truncated() {
+		os.Remove(o.spill.Name())
+		o.spill = nil
+	}
+}
+
+func (o *commandOutput) truncated() bool {
+	return o.total > len(o.head)+len(o.tail)
+}
+
+// String returns the output for the model, the head and the tail with a
+// note of how much was omitted between them
+func (o *commandOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.truncated() {
+		return string(o.head) + string(o.tail)
+	}
+	omitted := o.total - len(o.head) - len(o.tail)
+	note := fmt.Sprintf("[%d bytes omitted]", omitted)
+	if o.spill != nil {
+		note = fmt.Sprintf("[%d bytes omitted, the full output is in %s]", omitted, o.spill.Name())
+	}
+	return string(o.head) + "\n... " + note + " ...\n" + string(o.tail)
+}
@@ -0,0 +1,412 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxHunkFuzz is how many context lines at either end of a hunk may be
+// ignored to find where it applies
+const maxHunkFuzz = 2
+
+// filePatch is the change a patch makes to one file
+type filePatch struct {
+	Path   string
+	Add    bool
+	Delete bool
+	Hunks  []hunk
+}
+
+// hunk is a change to consecutive lines. Each line starts with ' ' for
+// context, '-' for a removed line or '+' for an added line.
+type hunk struct {
+	// OldStart is the line number the hunk starts at, 0 if unknown
+	OldStart int
+	Lines    []string
+}
+
+// old returns the lines the hunk expects in the file
+func (h hunk) old() []string {
+	var lines []string
+	for _, line := range h.Lines {
+		if line[0] != '+' {
+			lines = append(lines, line[1:])
+		}
+	}
+	return lines
+}
+
+// trim returns the hunk without up to n context lines at each end. A hunk
+// with context keeps at least one context line, so that it still has to
+// match the file.
+func (h hunk) trim(n int) hunk {
+	leading, trailing := 0, 0
+	for leading < len(h.Lines) && h.Lines[leading][0] == ' ' {
+		leading++
+	}
+	for trailing < len(h.Lines)-leading && h.Lines[len(h.Lines)-1-trailing][0] == ' ' {
+		trailing++
+	}
+	context := 0
+	for _, line := range h.Lines {
+		if line[0] == ' ' {
+			context++
+		}
+	}
+	leading, trailing = min(n, leading), min(n, trailing)
+	if context > 0 && context == leading+trailing {
+		if trailing > 0 {
+			trailing--
+		} else {
+			leading--
+		}
+	}
+
+	h.Lines = h.Lines[leading : len(h.Lines)-trailing]
+	h.OldStart += leading
+	return h
+}
+
+var hunkHeaderRegexp = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+
+// parsePatch parses a unified diff, or a patch envelope starting with
+// "*** Begin Patch" and its files marked with "*** Update File: path",
+// "*** Add File: path" or "*** Delete File: path"
+func parsePatch(text string) ([]filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "*** Begin Patch" {
+			return parseEnvelope(lines)
+		}
+	}
+	return parseUnifiedDiff(lines)
+}
+
+func parseUnifiedDiff(lines []string) ([]filePatch, error) {
+	var patches []filePatch
+	for i := 0; i < len(lines); i++ {
+		if !isFileHeader(lines, i) {
+			continue
+		}
+		oldPath := diffPath(lines[i][4:], "a/")
+		newPath := diffPath(lines[i+1][4:], "b/")
+		i += 2
+
+		patch := filePatch{Path: newPath}
+		switch {
+		case oldPath == "" && newPath == "":
+			return nil, fmt.Errorf("line %d: the file header names no file", i-1)
+		case oldPath == "":
+			patch.Add = true
+		case newPath == "":
+			patch.Path = oldPath
+			patch.Delete = true
+		case oldPath != newPath:
+			return nil, fmt.Errorf("line %d: renaming %s to %s isn't supported", i-1, oldPath, newPath)
+		}
+
+		for i < len(lines) && !isFileHeader(lines, i) {
+			match := hunkHeaderRegexp.FindStringSubmatch(lines[i])
+			if match == nil {
+				if strings.HasPrefix(lines[i], "@@") {
+					return nil, fmt.Errorf("line %d: invalid hunk header %q", i+1, lines[i])
+				}
+				break
+			}
+			start, _ := strconv.Atoi(match[1])
+			h := hunk{OldStart: start}
+			// The counts of the header tell where the hunk ends, as its lines
+			// may look like file headers, e.g. a removed "-- comment"
+			oldCount, newCount := hunkCount(match[2]), hunkCount(match[3])
+			header := i
+			for i++; i < len(lines) && (oldCount > 0 || newCount > 0) && isHunkLine(lines[i]); i++ {
+				h.Lines = appendHunkLine(h.Lines, lines[i])
+				switch {
+				case lines[i] == "", lines[i][0] == ' ':
+					oldCount--
+					newCount--
+				case lines[i][0] == '-':
+					oldCount--
+				case lines[i][0] == '+':
+					newCount--
+				}
+			}
+			if i < len(lines) && strings.HasPrefix(lines[i], "\\") {
+				i++
+			}
+			if oldCount < 0 || newCount < 0 || (i < len(lines) && lines[i] != "" &&
+				strings.ContainsRune(" -+", rune(lines[i][0])) && !isFileHeader(lines, i)) {
+				return nil, fmt.Errorf("line %d: the hunk has more lines than its header %q counts", header+1, lines[header])
+			}
+			patch.Hunks = append(patch.Hunks, trimBlankLines(h))
+		}
+		i--
+
+		if len(patch.Hunks) == 0 && !patch.Delete {
+			return nil, fmt.Errorf("the patch of %s has no hunks", patch.Path)
+		}
+		patches = append(patches, patch)
+	}
+
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no file headers (--- and +++ lines) found in the patch")
+	}
+	return patches, nil
+}
+
+func parseEnvelope(lines []string) ([]filePatch, error) {
+	var patches []filePatch
+	var current *filePatch
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "*** Begin Patch", trimmed == "*** End of File":
+			continue
+		case trimmed == "*** End Patch":
+			return finishEnvelope(patches)
+		case strings.HasPrefix(line, "*** Update File: "):
+			patches = append(patches, filePatch{Path: strings.TrimSpace(line[len("*** Update File: "):])})
+		case strings.HasPrefix(line, "*** Add File: "):
+			patches = append(patches, filePatch{Path: strings.TrimSpace(line[len("*** Add File: "):]), Add: true})
+		case strings.HasPrefix(line, "*** Delete File: "):
+			patches = append(patches, filePatch{Path: strings.TrimSpace(line[len("*** Delete File: "):]), Delete: true})
+		case strings.HasPrefix(line, "*** Move to: "):
+			return nil, fmt.Errorf("line %d: moving files isn't supported", i+1)
+		case strings.HasPrefix(line, "***"):
+			return nil, fmt.Errorf("line %d: unknown marker %q", i+1, line)
+		case len(patches) == 0:
+			if trimmed != "" {
+				return nil, fmt.Errorf("line %d: expected a file marker, got %q", i+1, line)
+			}
+			continue
+		}
+
+		current = &patches[len(patches)-1]
+		switch {
+		case strings.HasPrefix(line, "*** "):
+			// A file marker, handled above
+		case current.Delete:
+			if trimmed != "" {
+				return nil, fmt.Errorf("line %d: a deleted file has no content", i+1)
+			}
+		case strings.HasPrefix(line, "@@"):
+			current.Hunks = append(current.Hunks, hunk{})
+		case isHunkLine(line):
+			if current.Add && line != "" && line[0] != '+' {
+				return nil, fmt.Errorf("line %d: the lines of an added file start with +", i+1)
+			}
+			if len(current.Hunks) == 0 {
+				current.Hunks = append(current.Hunks, hunk{})
+			}
+			h := &current.Hunks[len(current.Hunks)-1]
+			h.Lines = appendHunkLine(h.Lines, line)
+		default:
+			return nil, fmt.Errorf("line %d: expected a line starting with space, - or +, got %q", i+1, line)
+		}
+	}
+	return nil, fmt.Errorf("the patch has no \"*** End Patch\" line")
+}
+
+func finishEnvelope(patches []filePatch) ([]filePatch, error) {
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("the patch changes no files")
+	}
+	for i := range patches {
+		patch := &patches[i]
+		hunks := patch.Hunks[:0]
+		for _, h := range patch.Hunks {
+			if h = trimBlankLines(h); len(h.Lines) > 0 {
+				hunks = append(hunks, h)
+			}
+		}
+		patch.Hunks = hunks
+		if patch.Add {
+			// Blank lines within an added file lost their +
+			for _, h := range hunks {
+				for j, line := range h.Lines {
+					if line == " " {
+						h.Lines[j] = "+"
+					}
+				}
+			}
+		}
+		if len(hunks) == 0 && !patch.Delete && !patch.Add {
+			return nil, fmt.Errorf("the patch of %s has no hunks", patch.Path)
+		}
+	}
+	return patches, nil
+}
+
+// hunkCount returns the line count of a hunk header, which is 1 when omitted
+func hunkCount(count string) int {
+	if count == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(count)
+	return n
+}
+
+// isFileHeader reports whether lines[i] starts the --- and +++ header of a file
+func isFileHeader(lines []string, i int) bool {
+	return i+1 < len(lines) && strings.HasPrefix(lines[i], "--- ") && strings.HasPrefix(lines[i+1], "+++ ")
+}
+
+func isHunkLine(line string) bool {
+	return line == "" || strings.ContainsRune(" -+\\", rune(line[0]))
+}
+
+// appendHunkLine appends a line of a hunk, taking an empty line for an empty
+// context line whose space was lost and skipping "\ No newline at end of file"
+func appendHunkLine(lines []string, line string) []string {
+	switch {
+	case line == "":
+		return append(lines, " ")
+	case line[0] == '\\':
+		return lines
+	}
+	return append(lines, line)
+}
+
+// trimBlankLines removes blank context lines at the end of h, which are
+// more likely separators than context
+func trimBlankLines(h hunk) hunk {
+	for len(h.Lines) > 0 && h.Lines[len(h.Lines)-1] == " " {
+		h.Lines = h.Lines[:len(h.Lines)-1]
+	}
+	return h
+}
+
+// diffPath returns the path of a --- or +++ line, or an empty string for
+// /dev/null
+func diffPath(header, prefix string) string {
+	path, _, _ := strings.Cut(header, "\t")
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(path, prefix)
+}
+
+// applyHunks applies hunks to content in order. A hunk applies where its
+// lines match closest to its line number, first exactly, then ignoring
+// whitespace at the end of lines, then ignoring indentation, and each of
+// these with up to maxHunkFuzz context lines ignored at either end.
+func applyHunks(content string, hunks []hunk) (string, error) {
+	lines := strings.Split(content, "\n")
+	// The last line ends with a newline unless there is text after it
+	trailingNewline := lines[len(lines)-1] == ""
+	if trailingNewline {
+		lines = lines[:len(lines)-1]
+	}
+
+	from, offset := 0, 0
+	for i, h := range hunks {
+		position, matched, ok := findHunk(lines, h, from, offset)
+		if !ok {
+			return "", fmt.Errorf("hunk %d doesn't match the file, it expects:\n%s", i+1, strings.Join(h.old(), "\n"))
+		}
+
+		var replacement []string
+		at := position
+		for _, line := range matched.Lines {
+			switch line[0] {
+			case ' ':
+				// Keep the file's line, which may differ in whitespace
+				replacement = append(replacement, lines[at])
+				at++
+			case '-':
+				at++
+			case '+':
+				replacement = append(replacement, line[1:])
+			}
+		}
+		lines = append(lines[:position], append(replacement, lines[at:]...)...)
+
+		from = position + len(replacement)
+		offset += len(replacement) - (at - position)
+	}
+
+	if len(lines) == 0 {
+		return "", nil
+	}
+	result := strings.Join(lines, "\n")
+	if trailingNewline {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// lineComparisons are the ways hunk lines are compared to the file, from the
+// strictest
+var lineComparisons = []func(a, b string) bool{
+	func(a, b string) bool { return a == b },
+	func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+	func(a, b string) bool { return strings.TrimSpace(a) == strings.TrimSpace(b) },
+}
+
+// findHunk returns where h applies in lines at or after from, and the hunk
+// with the context lines that were ignored to find it removed. offset is how
+// far the hunks before it moved the lines.
+func findHunk(lines []string, h hunk, from, offset int) (int, hunk, bool) {
+	for _, equal := range lineComparisons {
+		previous := -1
+		for fuzz := 0; fuzz <= maxHunkFuzz; fuzz++ {
+			trimmed := h.trim(fuzz)
+			if len(trimmed.Lines) == previous {
+				break
+			}
+			previous = len(trimmed.Lines)
+			old := trimmed.old()
+			if len(old) == 0 {
+				// Only added lines: they go at the line number, or at the end
+				position := len(lines)
+				if h.OldStart > 0 {
+					position = min(max(trimmed.OldStart+offset, from), len(lines))
+				}
+				return position, trimmed, true
+			}
+
+			expected := from
+			if trimmed.OldStart > 0 {
+				expected = trimmed.OldStart - 1 + offset
+			}
+			if position, ok := closestMatch(lines, old, from, expected, equal); ok {
+				return position, trimmed, true
+			}
+		}
+	}
+	return 0, h, false
+}
+
+// closestMatch returns the position at or after from where old matches lines
+// that is closest to expected
+func closestMatch(lines, old []string, from, expected int, equal func(a, b string) bool) (int, bool) {
+	best, found := 0, false
+	for position := from; position+len(old) <= len(lines); position++ {
+		if !linesMatch(lines[position:position+len(old)], old, equal) {
+			continue
+		}
+		if !found || abs(position-expected) < abs(best-expected) {
+			best, found = position, true
+		}
+	}
+	return best, found
+}
+
+func linesMatch(a, b []string, equal func(a, b string) bool) bool {
+	for i := range b {
+		if !equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
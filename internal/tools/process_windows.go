@@ -0,0 +1,15 @@
+//go:build windows
+
+package tools
+
+import (
+	"os/exec"
+	"time"
+)
+
+// killProcessGroup makes cancelling the context of cmd kill the process. Its
+// children are not killed on Windows.
+func killProcessGroup(cmd *exec.Cmd) {
+	// Don't wait forever for output pipes held open by orphaned processes
+	cmd.WaitDelay = 5 * time.Second
+}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"github.com/recrsn/coder/internal/schema"
 	"os"
@@ -8,11 +9,13 @@ import (
 	"strings"
 )
 
-// NewReadTool creates a tool for reading files
-func NewReadTool() *Tool {
+// NewReadTool creates a tool for reading files, which it records in files
+func NewReadTool(files *FileTracker) *Tool {
 	return &Tool{
 		Name:        "read",
 		Description: "Read content from a file",
+		PathArgs:    []string{"path"},
+		ReadOnly:    true,
 		InputSchema: schema.Schema{
 			Type: "object",
 			Properties: map[string]schema.Property{
@@ -58,7 +61,7 @@ func NewReadTool() *Tool {
 				Context: content,
 			}
 		},
-		Execute: func(input map[string]any) (string, error) {
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
 			// Extract parameters
 			path, _ := input["path"].(string)
 			startFloat, hasStart := input["start"].(float64)
@@ -92,6 +95,7 @@ func NewReadTool() *Tool {
 			if err != nil {
 				return "", fmt.Errorf("failed to read file: %w", err)
 			}
+			files.Record(absPath, content)
 
 			// Convert to string and split by lines
 			lines := strings.Split(string(content), "\n")
@@ -1,16 +1,22 @@
 package tools
 
-import "github.com/recrsn/coder/internal/llm"
+import (
+	"time"
+
+	"github.com/recrsn/coder/internal/llm"
+)
 
 // Registry holds all available tools
 type Registry struct {
-	tools map[string]*Tool
+	tools    map[string]*Tool
+	timeouts map[string]time.Duration
 }
 
 // NewRegistry creates a new registry with all tools
 func NewRegistry() *Registry {
 	registry := &Registry{
-		tools: make(map[string]*Tool),
+		tools:    make(map[string]*Tool),
+		timeouts: make(map[string]time.Duration),
 	}
 
 	return registry
@@ -18,15 +24,46 @@ func NewRegistry() *Registry {
 
 // Register adds a tool to the registry
 func (r *Registry) Register(name string, tool *Tool) {
+	if timeout, ok := r.timeouts[name]; ok {
+		tool.Timeout = timeout
+	}
 	r.tools[name] = tool
 }
 
+// SetTimeouts overrides the default timeouts of tools by name, both of the
+// tools registered already and of those registered later
+func (r *Registry) SetTimeouts(timeouts map[string]time.Duration) {
+	for name, timeout := range timeouts {
+		r.timeouts[name] = timeout
+		if tool, ok := r.tools[name]; ok {
+			tool.Timeout = timeout
+		}
+	}
+}
+
 // Get retrieves a tool from the registry
 func (r *Registry) Get(name string) (*Tool, bool) {
 	tool, ok := r.tools[name]
 	return tool, ok
 }
 
+// IsReadOnly reports whether the named tool is registered and read-only
+func (r *Registry) IsReadOnly(name string) bool {
+	tool, ok := r.tools[name]
+	return ok && tool.ReadOnly
+}
+
+// ReadOnly returns a registry with only the read-only tools of r
+func (r *Registry) ReadOnly() *Registry {
+	registry := NewRegistry()
+	for name, tool := range r.tools {
+		if tool.ReadOnly {
+			registry.Register(name, tool)
+		}
+	}
+	return registry
+}
+
 // ListTools returns a list of all available tool names
 func (r *Registry) ListTools() []llm.Tool {
 	var tools []llm.Tool
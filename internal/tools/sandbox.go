@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sandbox confines shell commands: only the workspace is writable, the rest
+// of the filesystem is read-only and the network is disabled
+type Sandbox struct {
+	// WorkspaceDirs returns the directories of the workspace, which can change
+	// during a session
+	WorkspaceDirs func() []string
+	// WritableDirs are writable besides the workspace
+	WritableDirs []string
+	Network      bool
+	// CPUSeconds, MemoryMB and MaxProcesses limit each command, 0 for no
+	// limit. MaxProcesses counts all processes of the user, not only those of
+	// the sandbox.
+	CPUSeconds   int
+	MemoryMB     int
+	MaxProcesses int
+}
+
+// writableDirs returns the directories commands may write to
+func (s *Sandbox) writableDirs() []string {
+	var dirs []string
+	if s.WorkspaceDirs != nil {
+		dirs = append(dirs, s.WorkspaceDirs()...)
+	}
+	for _, dir := range s.WritableDirs {
+		if strings.HasPrefix(dir, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				dir = home + dir[1:]
+			}
+		}
+		// Missing directories can't be mounted
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// readOnlyPaths returns the paths inside dirs that stay read-only: the .git
+// of a repository, as hooks and config written there run outside the sandbox
+// the next time the user runs git
+func readOnlyPaths(dirs []string) []string {
+	var paths []string
+	for _, dir := range dirs {
+		path := filepath.Join(dir, ".git")
+		if _, err := os.Lstat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// sandboxFailureHints are messages of failures the sandbox may have caused
+var sandboxFailureHints = []string{
+	"Read-only file system",
+	"Network is unreachable",
+	"Temporary failure in name resolution",
+	"Could not resolve host",
+	"Resource temporarily unavailable",
+	"Cannot allocate memory",
+	"CPU time limit exceeded",
+}
+
+// explainFailure returns a note for the model when output looks like the
+// sandbox blocked the command, or an empty string
+func (s *Sandbox) explainFailure(output string) string {
+	for _, hint := range sandboxFailureHints {
+		if strings.Contains(output, hint) {
+			network := "network access is disabled"
+			if s.Network {
+				network = "network access is allowed"
+			}
+			return fmt.Sprintf("\nNote: this command ran in a sandbox where only %s and a private /tmp are writable, "+
+				"except for .git directories, %s, "+
+				"and CPU time, memory and processes are limited. The error above (%q) was probably caused by the sandbox. "+
+				"Don't try to work around it; change the approach or ask the user to run the command.\n",
+				strings.Join(s.writableDirs(), ", "), network, hint)
+		}
+	}
+	return ""
+}
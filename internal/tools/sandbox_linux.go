@@ -0,0 +1,219 @@
+//go:build linux
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// sandboxHelperArg makes the coder binary run as the sandbox helper,
+	// which sets up the sandbox and then executes the command
+	sandboxHelperArg = "__sandbox"
+	// sandboxSpecEnv passes the sandboxSpec to the helper
+	sandboxSpecEnv = "CODER_SANDBOX_SPEC"
+	// sandboxHelperExitCode is the helper's exit code when it fails
+	sandboxHelperExitCode = 125
+)
+
+// sandboxSpec is what the helper sets up before executing the command
+type sandboxSpec struct {
+	// Mount makes the filesystem read-only except for WritableDirs, when the
+	// helper runs in new namespaces rather than bubblewrap doing this
+	Mount        bool     `json:"mount"`
+	WritableDirs []string `json:"writable_dirs"`
+	// ReadOnlyPaths inside WritableDirs stay read-only
+	ReadOnlyPaths []string `json:"read_only_paths"`
+	CPUSeconds    int      `json:"cpu_seconds"`
+	MemoryMB      int      `json:"memory_mb"`
+	MaxProcesses  int      `json:"max_processes"`
+}
+
+// command returns a command that runs argv in the sandbox. It uses
+// bubblewrap if it is installed and user, mount and network namespaces
+// otherwise.
+func (s *Sandbox) command(ctx context.Context, argv []string) (*exec.Cmd, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("finding the sandbox helper: %w", err)
+	}
+
+	spec := sandboxSpec{
+		CPUSeconds:   s.CPUSeconds,
+		MemoryMB:     s.MemoryMB,
+		MaxProcesses: s.MaxProcesses,
+	}
+	dirs := s.writableDirs()
+	readOnly := readOnlyPaths(dirs)
+
+	var args []string
+	if bwrap, err := exec.LookPath("bwrap"); err == nil {
+		args = []string{bwrap, "--die-with-parent", "--unshare-pid",
+			"--ro-bind", "/", "/", "--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp"}
+		for _, dir := range dirs {
+			args = append(args, "--bind", dir, dir)
+		}
+		for _, path := range readOnly {
+			args = append(args, "--ro-bind", path, path)
+		}
+		if !s.Network {
+			args = append(args, "--unshare-net")
+		}
+		args = append(append(args, "--"), argv...)
+	} else {
+		spec.Mount = true
+		spec.WritableDirs = dirs
+		spec.ReadOnlyPaths = readOnly
+		args = argv
+	}
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("encoding the sandbox: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, self, append([]string{sandboxHelperArg}, args...)...)
+	cmd.Env = append(os.Environ(), sandboxSpecEnv+"="+string(data))
+	killProcessGroup(cmd)
+
+	if spec.Mount {
+		// The helper is root in its namespace so that it may mount
+		cmd.SysProcAttr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS
+		if !s.Network {
+			cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+		}
+		cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+		cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	}
+	return cmd, nil
+}
+
+// HandleSandboxHelper runs the sandbox helper and exits if the process was
+// started as one, and returns otherwise. main calls it before anything else.
+func HandleSandboxHelper() {
+	if len(os.Args) < 3 || os.Args[1] != sandboxHelperArg {
+		return
+	}
+
+	err := runSandboxHelper(os.Args[2:])
+	// The helper only returns if it failed to execute the command
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(sandboxHelperExitCode)
+}
+
+// runSandboxHelper sets up the sandbox described by the environment and
+// replaces the process with argv
+func runSandboxHelper(argv []string) error {
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(os.Getenv(sandboxSpecEnv)), &spec); err != nil {
+		return fmt.Errorf("reading the sandbox spec: %w", err)
+	}
+	if err := os.Unsetenv(sandboxSpecEnv); err != nil {
+		return err
+	}
+
+	if spec.Mount {
+		if err := mountSandbox(spec.WritableDirs, spec.ReadOnlyPaths); err != nil {
+			return err
+		}
+	}
+	if err := limitResources(spec); err != nil {
+		return err
+	}
+
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, argv, os.Environ())
+}
+
+// mountSandbox makes every mount read-only except for dirs, without the
+// readOnly paths inside them, and a private /tmp. It must run in new user and
+// mount namespaces.
+func mountSandbox(dirs, readOnly []string) error {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	// Keep the changes to this namespace
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("making mounts private: %w", err)
+	}
+
+	// Open the writable directories first, the tmpfs hides those inside /tmp
+	fds := make([]int, len(dirs))
+	for i, dir := range dirs {
+		fd, err := unix.Open(dir, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("opening %s: %w", dir, err)
+		}
+		defer unix.Close(fd)
+		fds[i] = fd
+	}
+	if err := unix.Mount("tmpfs", "/tmp", "tmpfs", 0, ""); err != nil {
+		return fmt.Errorf("mounting /tmp: %w", err)
+	}
+
+	// Bind the writable directories onto themselves, so they become mounts
+	// that can be made writable again after everything is made read-only
+	for i, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("mounting %s: %w", dir, err)
+		}
+		source := fmt.Sprintf("/proc/self/fd/%d", fds[i])
+		if err := unix.Mount(source, dir, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("mounting %s: %w", dir, err)
+		}
+	}
+	if err := unix.MountSetattr(-1, "/", unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY}); err != nil {
+		return fmt.Errorf("making the filesystem read-only: %w", err)
+	}
+	for _, dir := range append([]string{"/tmp"}, dirs...) {
+		if err := unix.MountSetattr(-1, dir, unix.AT_RECURSIVE, &unix.MountAttr{Attr_clr: unix.MOUNT_ATTR_RDONLY}); err != nil {
+			return fmt.Errorf("making %s writable: %w", dir, err)
+		}
+	}
+	for _, path := range readOnly {
+		if err := unix.Mount(path, path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("mounting %s: %w", path, err)
+		}
+		if err := unix.MountSetattr(-1, path, unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY}); err != nil {
+			return fmt.Errorf("making %s read-only: %w", path, err)
+		}
+	}
+
+	// Enter the new mount of the working directory
+	return os.Chdir(workingDir)
+}
+
+// limitResources applies the resource limits of spec, which the command
+// and its children inherit
+func limitResources(spec sandboxSpec) error {
+	limits := []struct {
+		resource int
+		value    int
+	}{
+		{unix.RLIMIT_CPU, spec.CPUSeconds},
+		{unix.RLIMIT_AS, spec.MemoryMB * 1024 * 1024},
+		{unix.RLIMIT_NPROC, spec.MaxProcesses},
+	}
+	for _, limit := range limits {
+		if limit.value <= 0 {
+			continue
+		}
+		value := uint64(limit.value)
+		if err := unix.Setrlimit(limit.resource, &unix.Rlimit{Cur: value, Max: value}); err != nil {
+			return fmt.Errorf("limiting resources: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+//go:build linux
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// The sandbox runs the test binary as its helper
+	HandleSandboxHelper()
+	os.Exit(m.Run())
+}
+
+func TestShellToolSandbox(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.Mkdir(filepath.Join(workspace, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The sandbox's own /tmp hides directories in /tmp, so use another one
+	outside, err := os.MkdirTemp(".", "sandbox-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(outside) })
+	if outside, err = filepath.Abs(outside); err != nil {
+		t.Fatal(err)
+	}
+
+	shell := NewShell(ShellOptions{Sandbox: &Sandbox{
+		WorkspaceDirs: func() []string { return []string{workspace} },
+	}})
+	t.Cleanup(shell.Close)
+	tool := NewShellTool(shell)
+	run := func(command string) string {
+		t.Helper()
+		result, err := tool.Run(context.Background(), map[string]any{"command": command})
+		if err != nil {
+			if strings.Contains(err.Error(), "setting up the sandbox failed") {
+				t.Skipf("namespaces are unavailable: %v", err)
+			}
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		command  string
+		expected []string
+	}{
+		{"workspace is writable", "echo ok > " + filepath.Join(workspace, "file") + " && cat " + filepath.Join(workspace, "file"),
+			[]string{"Exit Code: 0", "ok"}},
+		{".git is read-only", "echo no > " + filepath.Join(workspace, ".git", "config"),
+			[]string{"Read-only file system"}},
+		{"tmp is writable", "echo ok > /tmp/file && cat /tmp/file", []string{"Exit Code: 0", "ok"}},
+		{"outside is read-only", "echo no > " + filepath.Join(outside, "file"),
+			[]string{"Read-only file system", "Note: this command ran in a sandbox"}},
+		{"network is disabled", "ls /sys/class/net", []string{"Exit Code: 0", "lo"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := run(tc.command)
+			for _, expected := range tc.expected {
+				if !strings.Contains(result, expected) {
+					t.Errorf("Expected %q in result:\n%s", expected, result)
+				}
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(outside, "file")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file outside the workspace, got %v", err)
+	}
+}
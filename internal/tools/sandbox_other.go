@@ -0,0 +1,21 @@
+//go:build !linux
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// sandboxHelperExitCode is the helper's exit code when it fails
+const sandboxHelperExitCode = 125
+
+// command fails, the sandbox needs Linux namespaces
+func (s *Sandbox) command(ctx context.Context, argv []string) (*exec.Cmd, error) {
+	return nil, fmt.Errorf("the shell sandbox isn't supported on %s, disable tools.sandbox.enabled to run commands", runtime.GOOS)
+}
+
+// HandleSandboxHelper does nothing, there is no sandbox helper on this platform
+func HandleSandboxHelper() {}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/recrsn/coder/internal/schema"
@@ -8,11 +9,38 @@ import (
 	"strings"
 )
 
-// NewSearchReplaceTool creates a tool to search for exact matches and replace them
-func NewSearchReplaceTool() *Tool {
+// searchEdit is a replacement made by search_replace
+type searchEdit struct {
+	Search      string
+	Replacement string
+	ReplaceAll  bool
+}
+
+// NewSearchReplaceTool creates a tool to search for exact matches and replace
+// them. It only changes files as files recorded them.
+func NewSearchReplaceTool(files *FileTracker) *Tool {
+	editProperties := map[string]schema.Property{
+		"search": {
+			Type:        "string",
+			Description: "The exact string to search for",
+		},
+		"replacement": {
+			Type:        "string",
+			Description: "The replacement text",
+		},
+		"replace_all": {
+			Type:        "boolean",
+			Description: "Replace every occurrence instead of requiring exactly one",
+		},
+	}
+
 	return &Tool{
-		Name:        "search_replace",
-		Description: "Search for exact match of a given string and replace it with the given replacement",
+		Name: "search_replace",
+		Description: "Search for exact match of a given string and replace it with the given replacement. " +
+			"The search string must occur exactly once unless replace_all is set. Several replacements can be " +
+			"made at once with edits, applied in order: either all of them are made or, if one fails, none. " +
+			"When the search string isn't found, the most similar text in the file is reported.",
+		PathArgs: []string{"file"},
 		InputSchema: schema.Schema{
 			Type: "object",
 			Properties: map[string]schema.Property{
@@ -20,62 +48,149 @@ func NewSearchReplaceTool() *Tool {
 					Type:        "string",
 					Description: "The file to modify",
 				},
-				"search": {
-					Type:        "string",
-					Description: "The exact string to search for",
-				},
-				"replacement": {
-					Type:        "string",
-					Description: "The replacement text",
+				"search":      editProperties["search"],
+				"replacement": editProperties["replacement"],
+				"replace_all": editProperties["replace_all"],
+				"edits": {
+					Type:        "array",
+					Description: "Several replacements to make instead of search and replacement",
+					Items: &schema.Schema{
+						Type:       "object",
+						Properties: editProperties,
+						Required:   []string{"search", "replacement"},
+					},
 				},
 			},
-			Required: []string{"file", "search", "replacement"},
+			Required: []string{"file"},
 		},
 		Explain: func(input map[string]any) ExplainResult {
 			file, _ := input["file"].(string)
-			search, _ := input["search"].(string)
-			replacement, _ := input["replacement"].(string)
+			edits, err := parseSearchEdits(input)
+			if err != nil {
+				return ExplainResult{
+					Title:   fmt.Sprintf("SearchReplace(%s)", file),
+					Context: fmt.Sprintf("Invalid edits: %v", err),
+				}
+			}
 
-			title := fmt.Sprintf("SearchReplace(%s, %s, %s)", file, search, replacement)
-			content := fmt.Sprintf("Will edit file '%s' by replacing one occurrence of the search text with the replacement text", file)
+			title := fmt.Sprintf("SearchReplace(%s, %d edits)", file, len(edits))
+			content := fmt.Sprintf("Will edit file '%s' by making %d replacements", file, len(edits))
+			if len(edits) == 1 {
+				title = fmt.Sprintf("SearchReplace(%s, %s, %s)", file, edits[0].Search, edits[0].Replacement)
+				content = fmt.Sprintf("Will edit file '%s' by replacing one occurrence of the search text with the replacement text", file)
+				if edits[0].ReplaceAll {
+					content = fmt.Sprintf("Will edit file '%s' by replacing every occurrence of the search text with the replacement text", file)
+				}
+			}
+			if existing, err := os.ReadFile(file); err == nil {
+				if newContent, _, err := applySearchEdits(string(existing), edits); err == nil {
+					content += "\n\n" + explainDiff(file, string(existing), newContent)
+				}
+			}
 
 			return ExplainResult{
 				Title:   title,
 				Context: content,
 			}
 		},
-		Execute: func(input map[string]any) (string, error) {
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
 			file := input["file"].(string)
-			search := input["search"].(string)
-			replacement := input["replacement"].(string)
-
-			content, err := os.ReadFile(file)
+			edits, err := parseSearchEdits(input)
 			if err != nil {
 				return "", err
 			}
 
-			fileContent := string(content)
-
-			// Count occurrences
-			count := strings.Count(fileContent, search)
-
-			if count > 1 {
-				return "", errors.New("found multiple matches for the search string")
+			if err := files.CheckWrite(file); err != nil {
+				return "", err
 			}
-
-			if count == 0 {
-				return "No matches found. File unchanged.", nil
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return "", err
 			}
 
-			// Replace exactly one occurrence
-			newContent := strings.Replace(fileContent, search, replacement, 1)
-
-			err = os.WriteFile(file, []byte(newContent), 0644)
+			newContent, count, err := applySearchEdits(string(content), edits)
 			if err != nil {
+				return "", fmt.Errorf("%s: %w\nThe file is unchanged.", file, err)
+			}
+			if err := WriteFiles([]FileChange{{Path: file, Content: []byte(newContent)}}); err != nil {
 				return "", err
 			}
+			files.Record(file, []byte(newContent))
 
-			return fmt.Sprintf("Replaced 1 occurrence in %s", file), nil
+			switch {
+			case len(edits) > 1:
+				return fmt.Sprintf("Made %d edits (%d replacements) in %s", len(edits), count, file), nil
+			case count == 1:
+				return fmt.Sprintf("Replaced 1 occurrence in %s", file), nil
+			}
+			return fmt.Sprintf("Replaced %d occurrences in %s", count, file), nil
 		},
 	}
 }
+
+// parseSearchEdits returns the edits of input, given either as edits or as
+// search and replacement
+func parseSearchEdits(input map[string]any) ([]searchEdit, error) {
+	replaceAll, _ := input["replace_all"].(bool)
+	items, ok := input["edits"].([]any)
+	if !ok {
+		search, hasSearch := input["search"].(string)
+		replacement, hasReplacement := input["replacement"].(string)
+		if !hasSearch || !hasReplacement {
+			return nil, errors.New("either search and replacement or edits are required")
+		}
+		return []searchEdit{{Search: search, Replacement: replacement, ReplaceAll: replaceAll}}, nil
+	}
+
+	if len(items) == 0 {
+		return nil, errors.New("edits is empty")
+	}
+	edits := make([]searchEdit, len(items))
+	for i, item := range items {
+		fields, _ := item.(map[string]any)
+		search, hasSearch := fields["search"].(string)
+		replacement, hasReplacement := fields["replacement"].(string)
+		if !hasSearch || !hasReplacement {
+			return nil, fmt.Errorf("edit %d: search and replacement are required", i+1)
+		}
+		edit := searchEdit{Search: search, Replacement: replacement, ReplaceAll: replaceAll}
+		if all, ok := fields["replace_all"].(bool); ok {
+			edit.ReplaceAll = all
+		}
+		edits[i] = edit
+	}
+	return edits, nil
+}
+
+// applySearchEdits makes edits in order, each on the result of the previous
+// ones, and returns the result and the number of replacements
+func applySearchEdits(content string, edits []searchEdit) (string, int, error) {
+	total := 0
+	for i, edit := range edits {
+		prefix := ""
+		if len(edits) > 1 {
+			prefix = fmt.Sprintf("edit %d: ", i+1)
+		}
+
+		if edit.Search == "" {
+			return "", 0, fmt.Errorf("%sthe search string is empty", prefix)
+		}
+		count := strings.Count(content, edit.Search)
+		switch {
+		case count == 0:
+			return "", 0, fmt.Errorf("%sthe search string wasn't found. %s", prefix,
+				describeRegions(similarRegions(content, edit.Search)))
+		case count > 1 && !edit.ReplaceAll:
+			return "", 0, fmt.Errorf("%sfound %d matches for the search string; include more surrounding text "+
+				"to match exactly one, or set replace_all", prefix, count)
+		}
+
+		if edit.ReplaceAll {
+			content = strings.ReplaceAll(content, edit.Search, edit.Replacement)
+		} else {
+			content = strings.Replace(content, edit.Search, edit.Replacement, 1)
+		}
+		total += count
+	}
+	return content, total, nil
+}
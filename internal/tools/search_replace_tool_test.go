@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSearchReplaceTool(t *testing.T) {
+	original := "func main() {\n\tx := 1\n\ty := 1\n\tfmt.Println(x, y)\n}\n"
+
+	tests := []struct {
+		name     string
+		input    map[string]any
+		expected string
+		err      string
+	}{
+		{
+			name:     "single edit",
+			input:    map[string]any{"search": "x := 1", "replacement": "x := 2"},
+			expected: "func main() {\n\tx := 2\n\ty := 1\n\tfmt.Println(x, y)\n}\n",
+		},
+		{
+			name:  "multiple matches",
+			input: map[string]any{"search": ":= 1", "replacement": ":= 2"},
+			err:   "found 2 matches",
+		},
+		{
+			name:     "replace all",
+			input:    map[string]any{"search": ":= 1", "replacement": ":= 2", "replace_all": true},
+			expected: "func main() {\n\tx := 2\n\ty := 2\n\tfmt.Println(x, y)\n}\n",
+		},
+		{
+			name: "several edits",
+			input: map[string]any{"edits": []any{
+				map[string]any{"search": "x := 1", "replacement": "x := 2"},
+				map[string]any{"search": "x, y", "replacement": "x + y"},
+			}},
+			expected: "func main() {\n\tx := 2\n\ty := 1\n\tfmt.Println(x + y)\n}\n",
+		},
+		{
+			name: "failing edit changes nothing",
+			input: map[string]any{"edits": []any{
+				map[string]any{"search": "x := 1", "replacement": "x := 2"},
+				map[string]any{"search": "z := 1", "replacement": "z := 2"},
+			}},
+			err: "edit 2: the search string wasn't found",
+		},
+		{
+			name:  "whitespace difference is reported",
+			input: map[string]any{"search": "    y := 1\n    fmt.Println(x, y)", "replacement": ""},
+			err:   "Lines 3-4 (the same except for whitespace):\n     3\t\ty := 1\n     4\t\tfmt.Println(x, y)",
+		},
+		{
+			name:  "similar text is reported",
+			input: map[string]any{"search": "fmt.Println(x, z)", "replacement": ""},
+			err:   "Line 4 (94% similar):\n     4\t\tfmt.Println(x, y)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.go")
+			if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+				t.Fatal(err)
+			}
+			tt.input["file"] = path
+
+			_, err := NewSearchReplaceTool(nil).Run(context.Background(), tt.input)
+			content, _ := os.ReadFile(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected an error containing %q, got %v", tt.err, err)
+				}
+				if string(content) != original {
+					t.Errorf("Expected the file to be unchanged, got %q", content)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(content) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, content)
+			}
+		})
+	}
+}
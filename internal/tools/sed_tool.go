@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"github.com/recrsn/coder/internal/schema"
 	"os"
@@ -8,11 +9,13 @@ import (
 	"strings"
 )
 
-// NewSedTool creates a tool to perform string replacement in files
-func NewSedTool() *Tool {
+// NewSedTool creates a tool to perform string replacement in files. It only
+// changes files as files recorded them.
+func NewSedTool(files *FileTracker) *Tool {
 	return &Tool{
 		Name:        "sed",
 		Description: "Replace text in files",
+		PathArgs:    []string{"file"},
 		InputSchema: schema.Schema{
 			Type: "object",
 			Properties: map[string]schema.Property{
@@ -37,23 +40,27 @@ func NewSedTool() *Tool {
 		},
 		Explain: func(input map[string]any) ExplainResult {
 			file, _ := input["file"].(string)
-			useRegex, ok := input["useRegex"].(bool)
-			if !ok {
-				useRegex = false
-			}
+			pattern, _ := input["pattern"].(string)
+			replacement, _ := input["replacement"].(string)
+			useRegex, _ := input["useRegex"].(bool)
 
+			var context string
 			if useRegex {
-				return ExplainResult{
-					Title:   fmt.Sprintf("Sed(%s, %s, %s)", file, input["pattern"], input["replacement"]),
-					Context: fmt.Sprintf("Will edit file '%s' using regex pattern matching to replace all occurrences", file),
+				context = fmt.Sprintf("Will edit file '%s' using regex pattern matching to replace all occurrences", file)
+			} else {
+				context = fmt.Sprintf("Will edit file '%s' to replace all occurrences of '%s' with '%s'", file, pattern, replacement)
+			}
+			if content, err := os.ReadFile(file); err == nil {
+				if newContent, _, err := sedReplace(string(content), pattern, replacement, useRegex); err == nil {
+					context += "\n\n" + explainDiff(file, string(content), newContent)
 				}
 			}
 			return ExplainResult{
-				Title:   fmt.Sprintf("Sed(%s, %s, %s)", file, input["pattern"], input["replacement"]),
-				Context: fmt.Sprintf("Will edit file '%s' to replace all occurrences of '%s' with '%s'", file, input["pattern"], input["replacement"]),
+				Title:   fmt.Sprintf("Sed(%s, %s, %s)", file, pattern, replacement),
+				Context: context,
 			}
 		},
-		Execute: func(input map[string]any) (string, error) {
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
 			file := input["file"].(string)
 			pattern := input["pattern"].(string)
 			replacement := input["replacement"].(string)
@@ -62,37 +69,41 @@ func NewSedTool() *Tool {
 				useRegex = false
 			}
 
+			if err := files.CheckWrite(file); err != nil {
+				return "", err
+			}
 			content, err := os.ReadFile(file)
 			if err != nil {
 				return "", err
 			}
 
-			var newContent string
-			var count int
-
-			if useRegex {
-				regex, err := regexp.Compile(pattern)
-				if err != nil {
-					return "", err
-				}
-
-				newContentBytes := regex.ReplaceAll(content, []byte(replacement))
-				newContent = string(newContentBytes)
-
-				// Count replacements
-				count = strings.Count(string(content), pattern) - strings.Count(newContent, pattern)
-			} else {
-				// Simple string replacement
-				newContent = strings.ReplaceAll(string(content), pattern, replacement)
-				count = strings.Count(string(content), pattern)
+			newContent, count, err := sedReplace(string(content), pattern, replacement, useRegex)
+			if err != nil {
+				return "", err
 			}
 
-			err = os.WriteFile(file, []byte(newContent), 0644)
-			if err != nil {
+			if err := WriteFiles([]FileChange{{Path: file, Content: []byte(newContent)}}); err != nil {
 				return "", err
 			}
+			files.Record(file, []byte(newContent))
 
 			return fmt.Sprintf("Made %d replacements in %s", count, file), nil
 		},
 	}
 }
+
+// sedReplace replaces every occurrence of pattern in content and returns the
+// result and the number of replacements
+func sedReplace(content, pattern, replacement string, useRegex bool) (string, int, error) {
+	if !useRegex {
+		// Simple string replacement
+		return strings.ReplaceAll(content, pattern, replacement), strings.Count(content, pattern), nil
+	}
+
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", 0, err
+	}
+	count := len(regex.FindAllStringIndex(content, -1))
+	return regex.ReplaceAllString(content, replacement), count, nil
+}
@@ -0,0 +1,29 @@
+package tools
+
+import "testing"
+
+func TestSedReplace(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		useRegex bool
+		expected string
+		count    int
+	}{
+		{name: "string", pattern: "foo", expected: "bar bar fo", count: 2},
+		{name: "regex", pattern: "fo+", useRegex: true, expected: "bar bar bar", count: 3},
+		{name: "regex with groups", pattern: `(f)(o)\b`, useRegex: true, expected: "foo foo bar", count: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, count, err := sedReplace("foo foo fo", tt.pattern, "bar", tt.useRegex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != tt.expected || count != tt.count {
+				t.Errorf("Expected %q with %d replacements, got %q with %d", tt.expected, tt.count, result, count)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShellOptions configures the shell tool
+type ShellOptions struct {
+	// Sandbox confines commands, nil to run them unconfined
+	Sandbox *Sandbox
+	// OutputDir keeps the full output of commands whose output was truncated
+	// for the model. Without it the omitted part is lost.
+	OutputDir string
+}
+
+// Shell runs the commands of a session in one long-lived bash process, so
+// that the working directory and environment carry over between them
+type Shell struct {
+	options ShellOptions
+	// mu serializes commands and guards process
+	mu sync.Mutex
+	// process is the bash process, nil until the next command starts one
+	process *shellProcess
+
+	// jobsMu guards the background jobs
+	jobsMu       sync.Mutex
+	jobs         []*Job
+	nextJobID    int
+	onJobsChange func()
+}
+
+// NewShell creates a Shell. Its bash process starts with the first command.
+func NewShell(options ShellOptions) *Shell {
+	return &Shell{options: options}
+}
+
+// Run runs command and returns its exit code and output for the model. A
+// fresh command runs in a new sh process instead of the session's shell, as
+// do all commands if bash isn't installed.
+func (s *Shell) Run(ctx context.Context, command string, fresh bool) (string, error) {
+	if fresh {
+		return s.runOnce(ctx, command)
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		return s.runOnce(ctx, command)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runPersistent(ctx, command)
+}
+
+// Close stops the bash process and kills the running jobs
+func (s *Shell) Close() {
+	for _, job := range s.Jobs() {
+		job.stop()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.process != nil {
+		s.process.stop()
+		s.process = nil
+	}
+}
+
+// runOnce runs command in a new shell
+func (s *Shell) runOnce(ctx context.Context, command string) (string, error) {
+	cmd, err := s.command(ctx, []string{"sh", "-c", command})
+	if err != nil {
+		return "", err
+	}
+
+	output, err := newCommandOutput(s.options.OutputDir, outputFunc(ctx))
+	if err != nil {
+		return "", err
+	}
+	// Interleave both streams as a terminal would
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err = cmd.Run()
+	output.Close()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("command %q stopped: %w", command, ctx.Err())
+	}
+
+	// A non-zero exit is a result, and background processes holding the
+	// output open after the command exited are left alone
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) && !errors.Is(err, exec.ErrWaitDelay) {
+		return "", fmt.Errorf("running command %q: %w", command, err)
+	}
+	return s.result(command, cmd.ProcessState.ExitCode(), output.String(), "")
+}
+
+// runPersistent runs command in the session's bash process
+func (s *Shell) runPersistent(ctx context.Context, command string) (string, error) {
+	output, err := newCommandOutput(s.options.OutputDir, outputFunc(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer output.Close()
+
+	exitCode, exited, err := s.execute(ctx, command, output)
+	if err != nil {
+		return "", err
+	}
+
+	note := ""
+	if exited {
+		note = "\nThe shell exited. The next command runs in a new shell without the working directory and environment of this one.\n"
+	}
+	output.Close()
+	return s.result(command, exitCode, output.String(), note)
+}
+
+// execute runs command in the bash process and copies its output to output,
+// starting a process if there is none. The process is replaced if it exits,
+// which exited reports, or if command is stopped. s.mu must be held.
+func (s *Shell) execute(ctx context.Context, command string, output io.Writer) (exitCode int, exited bool, err error) {
+	if s.process != nil && s.process.hasExited() {
+		s.process = nil
+	}
+	if s.process == nil {
+		process, err := s.startProcess()
+		if err != nil {
+			return 0, false, err
+		}
+		s.process = process
+	}
+	process := s.process
+
+	// eval keeps a syntax error in command from breaking the framing, and
+	// the command can't read the script from stdin
+	script := fmt.Sprintf("eval %s < /dev/null\nprintf '\\n%%s%%d\\n' %s \"$?\"\n", shellQuote(command), process.sentinel)
+	if _, err := io.WriteString(process.stdin, script); err != nil {
+		process.stop()
+		s.process = nil
+		return 0, false, fmt.Errorf("writing to the shell: %w", err)
+	}
+
+	exitCode, err = process.wait(ctx, output)
+	if ctx.Err() != nil {
+		process.stop()
+		s.process = nil
+		return 0, false, fmt.Errorf("command %q stopped, the shell was restarted without its working directory and environment: %w",
+			command, ctx.Err())
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if process.hasExited() {
+		s.process = nil
+		return exitCode, true, nil
+	}
+	return exitCode, false, nil
+}
+
+// command returns a command running argv, in the sandbox if there is one
+func (s *Shell) command(ctx context.Context, argv []string) (*exec.Cmd, error) {
+	if s.options.Sandbox != nil {
+		cmd, err := s.options.Sandbox.command(ctx, argv)
+		if err != nil {
+			return nil, fmt.Errorf("starting sandbox: %w", err)
+		}
+		return cmd, nil
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	// Kill the whole process group on cancellation, not just the shell
+	killProcessGroup(cmd)
+	return cmd, nil
+}
+
+// result formats the result of a command for the model
+func (s *Shell) result(command string, exitCode int, output, note string) (string, error) {
+	sandbox := s.options.Sandbox
+	if sandbox != nil && exitCode == sandboxHelperExitCode && strings.HasPrefix(output, "sandbox: ") {
+		return "", fmt.Errorf("setting up the sandbox failed: %s", strings.TrimSpace(strings.TrimPrefix(output, "sandbox: ")))
+	}
+
+	result := "Command: " + command + "\n"
+	result += fmt.Sprintf("Exit Code: %d\n", exitCode)
+	if output != "" {
+		result += "\nOutput:\n" + output + "\n"
+	}
+	if sandbox != nil && exitCode != 0 {
+		result += sandbox.explainFailure(output)
+	}
+	return result + note, nil
+}
+
+// shellProcess is a running bash process. Each command it runs ends with a
+// line of the sentinel and the exit code.
+type shellProcess struct {
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	stop     context.CancelFunc
+	sentinel string
+
+	// mu guards pending, the output not yet taken by a command
+	mu      sync.Mutex
+	pending []byte
+	// notify signals new output, closed marks the end of the output and
+	// exited the end of the process
+	notify chan struct{}
+	closed chan struct{}
+	exited chan struct{}
+}
+
+// startProcess starts a bash process reading commands from stdin
+func (s *Shell) startProcess() (*shellProcess, error) {
+	sentinel := make([]byte, 16)
+	if _, err := rand.Read(sentinel); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd, err := s.command(ctx, []string{"bash", "--noprofile", "--norc"})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("starting the shell: %w", err)
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("starting the shell: %w", err)
+	}
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	err = cmd.Start()
+	writer.Close()
+	if err != nil {
+		reader.Close()
+		cancel()
+		return nil, fmt.Errorf("starting the shell: %w", err)
+	}
+
+	p := &shellProcess{
+		cmd:      cmd,
+		stdin:    stdin,
+		stop:     cancel,
+		sentinel: "__coder_" + hex.EncodeToString(sentinel) + "__",
+		notify:   make(chan struct{}, 1),
+		closed:   make(chan struct{}),
+		exited:   make(chan struct{}),
+	}
+	go p.read(reader)
+	go func() {
+		cmd.Wait()
+		cancel()
+		close(p.exited)
+	}()
+	return p, nil
+}
+
+// read collects the output of the process until every writer closed it
+func (p *shellProcess) read(reader *os.File) {
+	defer close(p.closed)
+	defer reader.Close()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			p.mu.Lock()
+			p.pending = append(p.pending, buf[:n]...)
+			p.mu.Unlock()
+			select {
+			case p.notify <- struct{}{}:
+			default:
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (p *shellProcess) hasExited() bool {
+	select {
+	case <-p.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait copies the output of the running command to output until its
+// sentinel line, and returns its exit code. If the process exits first it
+// returns the exit code of the process.
+func (p *shellProcess) wait(ctx context.Context, output io.Writer) (int, error) {
+	marker := []byte("\n" + p.sentinel)
+	var data []byte
+	for {
+		p.mu.Lock()
+		data = append(data, p.pending...)
+		p.pending = nil
+		p.mu.Unlock()
+
+		if i := bytes.Index(data, marker); i >= 0 {
+			output.Write(data[:i])
+			data = data[i:]
+			if end := bytes.IndexByte(data[len(marker):], '\n'); end >= 0 {
+				return strconv.Atoi(string(data[len(marker) : len(marker)+end]))
+			}
+		} else if keep := len(marker) - 1; len(data) > keep {
+			// The end could be the start of the marker
+			output.Write(data[:len(data)-keep])
+			data = data[len(data)-keep:]
+		}
+
+		select {
+		case <-p.notify:
+		case <-p.exited:
+			// Take the rest of the output unless background processes keep it open
+			select {
+			case <-p.closed:
+			case <-time.After(100 * time.Millisecond):
+			}
+			p.mu.Lock()
+			data = append(data, p.pending...)
+			p.pending = nil
+			p.mu.Unlock()
+			output.Write(data)
+			return p.cmd.ProcessState.ExitCode(), nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// shellQuote quotes s as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
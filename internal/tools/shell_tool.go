@@ -1,16 +1,24 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"github.com/recrsn/coder/internal/schema"
-	"os/exec"
+	"time"
 )
 
-// NewShellTool creates a tool to execute shell commands
-func NewShellTool() *Tool {
+// shellTimeout leaves room for builds and test suites
+const shellTimeout = 10 * time.Minute
+
+// NewShellTool creates a tool to execute shell commands in shell
+func NewShellTool(shell *Shell) *Tool {
 	return &Tool{
-		Name:        "shell",
-		Description: "Execute shell commands",
+		Name: "shell",
+		Description: "Execute shell commands. Commands run in one bash session, so the working directory and " +
+			"environment carry over between calls. Long output is truncated in the middle, the full output is saved to a file that can be read.",
+		CommandArg: "command",
+		Timeout:    shellTimeout,
+		TimeoutArg: "timeout",
 		InputSchema: schema.Schema{
 			Type: "object",
 			Properties: map[string]schema.Property{
@@ -18,6 +26,18 @@ func NewShellTool() *Tool {
 					Type:        "string",
 					Description: "The shell command to execute",
 				},
+				"timeout": {
+					Type:        "number",
+					Description: "Seconds after which the command is stopped, for commands that need more or less time than usual",
+				},
+				"background": {
+					Type:        "boolean",
+					Description: "Start the command in the background and return its job id at once, for servers and watchers. Read its output with job_output and stop it with job_kill.",
+				},
+				"fresh": {
+					Type:        "boolean",
+					Description: "Run the command in a new shell, without the working directory and environment of the session's shell",
+				},
 				"why": {
 					Type:        "string",
 					Description: "A very short reason for executing this command",
@@ -28,45 +48,25 @@ func NewShellTool() *Tool {
 		Explain: func(input map[string]any) ExplainResult {
 			command, _ := input["command"].(string)
 			why, _ := input["why"].(string)
+			if background, _ := input["background"].(bool); background {
+				command += ", background"
+			}
 			return ExplainResult{
 				Title:   fmt.Sprintf("Shell(%s)", command),
 				Context: why,
 			}
 		},
-		Execute: func(input map[string]any) (string, error) {
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
 			command := input["command"].(string)
-			cmd := exec.Command("sh", "-c", command)
-
-			stdout, err := cmd.Output()
-			if err != nil {
-				var exitCode int
-				var stderr string
-
-				if exitErr, ok := err.(*exec.ExitError); ok {
-					exitCode = exitErr.ExitCode()
-					stderr = string(exitErr.Stderr)
-				} else {
-					exitCode = 1
-					stderr = err.Error()
-				}
-
-				result := "Command: " + command + "\n"
-				result += fmt.Sprintf("Exit Code: %d\n", exitCode)
-				if len(stdout) > 0 {
-					result += "\nStandard Output:\n" + string(stdout) + "\n"
+			if background, _ := input["background"].(bool); background {
+				job, err := shell.StartJob(ctx, command)
+				if err != nil {
+					return "", err
 				}
-				if stderr != "" {
-					result += "\nStandard Error:\n" + stderr + "\n"
-				}
-				return result, nil
-			}
-
-			result := "Command: " + command + "\n"
-			result += "Exit Code: 0\n"
-			if len(stdout) > 0 {
-				result += "\nOutput:\n" + string(stdout) + "\n"
+				return fmt.Sprintf("Started job %d: %s\nRead its output with job_output and stop it with job_kill.\n", job.ID, command), nil
 			}
-			return result, nil
+			fresh, _ := input["fresh"].(bool)
+			return shell.Run(ctx, command, fresh)
 		},
 	}
 }
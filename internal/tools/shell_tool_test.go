@@ -0,0 +1,140 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellToolKillsProcessGroupOnTimeout(t *testing.T) {
+	tool := NewShellTool(NewShell(ShellOptions{}))
+	tool.Timeout = 200 * time.Millisecond
+
+	// The background sleep keeps stdout open unless the whole group is killed
+	start := time.Now()
+	_, err := tool.Run(context.Background(), map[string]any{"command": "sleep 30 & sleep 30"})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected the command to be killed promptly, took %s", elapsed)
+	}
+}
+
+func TestShellToolStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	_, err := NewShellTool(NewShell(ShellOptions{})).Run(ctx, map[string]any{"command": "sleep 30"})
+	if err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Fatalf("expected a cancellation error, got %v", err)
+	}
+}
+
+func TestShellToolTimeoutArgument(t *testing.T) {
+	start := time.Now()
+	_, err := NewShellTool(NewShell(ShellOptions{})).Run(context.Background(), map[string]any{"command": "sleep 30", "timeout": 0.2})
+	if err == nil || !strings.Contains(err.Error(), "timed out after 200ms") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected the command to be stopped promptly, took %s", elapsed)
+	}
+}
+
+func TestShellToolOutput(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		expected  []string
+		truncated bool
+	}{
+		{"merges stdout and stderr", "echo out; echo err >&2; exit 3",
+			[]string{"Exit Code: 3", "out\nerr\n"}, false},
+		{"truncates long output", "seq 1 20000",
+			[]string{"Exit Code: 0", "\n1\n2\n", "bytes omitted, the full output is in", "\n20000\n"}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var streamed strings.Builder
+			ctx := WithOutput(context.Background(), func(text string) { streamed.WriteString(text) })
+
+			result, err := NewShellTool(NewShell(ShellOptions{OutputDir: dir})).Run(ctx, map[string]any{"command": tc.command})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, expected := range tc.expected {
+				if !strings.Contains(result, expected) {
+					t.Errorf("Expected %q in result:\n%.500s", expected, result)
+				}
+			}
+
+			spills, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tc.truncated {
+				if len(spills) != 0 {
+					t.Errorf("Expected no output file, got %d", len(spills))
+				}
+				return
+			}
+			if len(spills) != 1 {
+				t.Fatalf("Expected one output file, got %d", len(spills))
+			}
+			full, err := os.ReadFile(filepath.Join(dir, spills[0].Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(full) != streamed.String() || !strings.HasSuffix(string(full), "\n19999\n20000\n") {
+				t.Errorf("Expected the full output in the file and streamed, got %d and %d bytes", len(full), streamed.Len())
+			}
+		})
+	}
+}
+
+func TestShellToolPersistentSession(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	dir := t.TempDir()
+	shell := NewShell(ShellOptions{})
+	t.Cleanup(shell.Close)
+	tool := NewShellTool(shell)
+
+	tests := []struct {
+		name     string
+		input    map[string]any
+		expected []string
+	}{
+		{"cd", map[string]any{"command": "cd " + dir}, []string{"Exit Code: 0"}},
+		{"export", map[string]any{"command": "export FOO=bar"}, []string{"Exit Code: 0"}},
+		{"keeps state", map[string]any{"command": "pwd; echo $FOO"}, []string{"Output:\n" + dir + "\nbar\n"}},
+		{"output without newline", map[string]any{"command": "printf partial"}, []string{"Output:\npartial\n"}},
+		{"syntax error", map[string]any{"command": "echo 'unterminated"}, []string{"Exit Code: 2"}},
+		{"ignores stdin", map[string]any{"command": "cat; echo after"}, []string{"Output:\nafter\n"}},
+		{"fresh shell", map[string]any{"command": "echo \"[$FOO]\"", "fresh": true}, []string{"Output:\n[]\n"}},
+		{"exit", map[string]any{"command": "exit 4"}, []string{"Exit Code: 4", "The shell exited"}},
+		{"restarted", map[string]any{"command": "echo \"[$FOO]\""}, []string{"Exit Code: 0", "Output:\n[]\n"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := tool.Run(context.Background(), tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, expected := range tc.expected {
+				if !strings.Contains(result, expected) {
+					t.Errorf("Expected %q in result:\n%s", expected, result)
+				}
+			}
+		})
+	}
+}
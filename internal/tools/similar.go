@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	// maxCandidates is how many similar regions are reported for a search
+	// text that isn't found
+	maxCandidates = 3
+	// minSimilarity is how similar a region must be to the search text to be
+	// reported
+	minSimilarity = 0.6
+)
+
+// similarRegion is a range of lines of a file similar to a search text
+type similarRegion struct {
+	// Start is the index of the first line
+	Start      int
+	Lines      []string
+	Similarity float64
+}
+
+// similarRegions returns the regions of content most similar to search when
+// whitespace is ignored, the most similar first
+func similarRegions(content, search string) []similarRegion {
+	searchLines := strings.Split(strings.Trim(search, "\n"), "\n")
+	lines := strings.Split(content, "\n")
+	if len(searchLines) > len(lines) {
+		return nil
+	}
+
+	dmp := diffmatchpatch.New()
+	normalized := make([]string, len(searchLines))
+	for i, line := range searchLines {
+		normalized[i] = normalizeSpace(line)
+	}
+	similarity := func(line string, i int) float64 {
+		line = normalizeSpace(line)
+		// The search text may start or end within a line
+		partial := (i == 0 || i == len(normalized)-1) && normalized[i] != "" && strings.Contains(line, normalized[i])
+		if line == normalized[i] || partial {
+			return 1
+		}
+		longest := max(len(line), len(normalized[i]))
+		distance := dmp.DiffLevenshtein(dmp.DiffMain(line, normalized[i], false))
+		return 1 - float64(distance)/float64(longest)
+	}
+
+	var regions []similarRegion
+	for start := 0; start+len(searchLines) <= len(lines); start++ {
+		total := 0.0
+		for i := range searchLines {
+			total += similarity(lines[start+i], i)
+		}
+		score := total / float64(len(searchLines))
+		if score >= minSimilarity {
+			regions = append(regions, similarRegion{
+				Start:      start,
+				Lines:      lines[start : start+len(searchLines)],
+				Similarity: score,
+			})
+		}
+	}
+
+	sort.SliceStable(regions, func(i, j int) bool { return regions[i].Similarity > regions[j].Similarity })
+	// Overlapping regions show the same lines, keep the best of them
+	var best []similarRegion
+	for _, region := range regions {
+		overlaps := false
+		for _, kept := range best {
+			if region.Start < kept.Start+len(kept.Lines) && kept.Start < region.Start+len(region.Lines) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			best = append(best, region)
+		}
+		if len(best) == maxCandidates {
+			break
+		}
+	}
+	return best
+}
+
+// normalizeSpace collapses runs of whitespace to single spaces and removes
+// them at the ends
+func normalizeSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// describeRegions lists regions with their line numbers for an error message
+func describeRegions(regions []similarRegion) string {
+	if len(regions) == 0 {
+		return "No similar text was found either."
+	}
+
+	var out strings.Builder
+	out.WriteString("The most similar text in the file:")
+	for _, region := range regions {
+		if len(region.Lines) == 1 {
+			fmt.Fprintf(&out, "\n\nLine %d", region.Start+1)
+		} else {
+			fmt.Fprintf(&out, "\n\nLines %d-%d", region.Start+1, region.Start+len(region.Lines))
+		}
+		if region.Similarity == 1 {
+			out.WriteString(" (the same except for whitespace)")
+		} else {
+			fmt.Fprintf(&out, " (%d%% similar)", int(region.Similarity*100))
+		}
+		out.WriteString(":\n")
+		for i, line := range region.Lines {
+			fmt.Fprintf(&out, "%6d\t%s\n", region.Start+i+1, line)
+		}
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
@@ -1,9 +1,20 @@
 package tools
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/recrsn/coder/internal/schema"
 )
 
+// DefaultTimeout is how long a tool without a timeout of its own may run
+const DefaultTimeout = 2 * time.Minute
+
+// MaxTimeout caps the timeout a call may ask for
+const MaxTimeout = time.Hour
+
 // ExplainResult represents the result of the Tool.Explain function
 type ExplainResult struct {
 	// Title is a short description of what the tool will do
@@ -15,18 +26,83 @@ type ExplainResult struct {
 type Tool struct {
 	Name        string
 	Description string
+	// ReadOnly tools don't modify anything, so several calls can run concurrently
+	ReadOnly    bool
 	InputSchema schema.Schema
-	Execute     func(input map[string]any) (string, error)
-	Explain     func(input map[string]any) ExplainResult
+	// Timeout bounds how long a call may run, DefaultTimeout if zero
+	Timeout time.Duration
+	// TimeoutArg names an argument with which a call can set its own timeout
+	// in seconds, up to MaxTimeout
+	TimeoutArg string
+	// Execute runs the tool. It should stop when ctx is cancelled.
+	Execute func(ctx context.Context, input map[string]any) (string, error)
+	Explain func(input map[string]any) ExplainResult
+	// CommandArg and PathArgs name the arguments holding the shell command and
+	// the paths of a call, which permission rules are matched against
+	CommandArg string
+	PathArgs   []string
+	// ExtraPaths returns paths of a call that aren't arguments of their own,
+	// e.g. the files a patch changes
+	ExtraPaths func(input map[string]any) []string
+}
+
+// Modifies reports whether a call may change files or run commands. Besides
+// ReadOnly tools, the agent tool doesn't, as it only runs ReadOnly tools, and
+// neither does job_output, which only consumes the output it reads.
+func (t *Tool) Modifies() bool {
+	return !t.ReadOnly && t.Name != "agent" && t.Name != "job_output"
+}
+
+// PermissionSubjects returns the command and paths of a call
+func (t *Tool) PermissionSubjects(input map[string]any) (command string, paths []string) {
+	if t.CommandArg != "" {
+		command, _ = input[t.CommandArg].(string)
+	}
+	for _, arg := range t.PathArgs {
+		switch value := input[arg].(type) {
+		case string:
+			if value != "" {
+				paths = append(paths, value)
+			}
+		case []any:
+			for _, item := range value {
+				if path, ok := item.(string); ok && path != "" {
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+	if t.ExtraPaths != nil {
+		paths = append(paths, t.ExtraPaths(input)...)
+	}
+	return command, paths
 }
 
 func (t *Tool) Validate(input map[string]any) error {
 	return t.InputSchema.Validate(input)
 }
 
-func (t *Tool) Run(input map[string]any) (string, error) {
+// Run validates input and executes the tool within its timeout
+func (t *Tool) Run(ctx context.Context, input map[string]any) (string, error) {
 	if err := t.Validate(input); err != nil {
 		return "", err
 	}
-	return t.Execute(input)
+
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if t.TimeoutArg != "" {
+		if seconds, ok := input[t.TimeoutArg].(float64); ok && seconds > 0 {
+			timeout = min(time.Duration(seconds*float64(time.Second)), MaxTimeout)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result, err := t.Execute(ctx, input)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return result, fmt.Errorf("timed out after %s: %w", timeout, err)
+	}
+	return result, err
 }
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"github.com/recrsn/coder/internal/schema"
 	"os"
@@ -13,6 +14,8 @@ func NewTreeTool() *Tool {
 	return &Tool{
 		Name:        "tree",
 		Description: "Display directory structure in a tree format",
+		PathArgs:    []string{"path"},
+		ReadOnly:    true,
 		InputSchema: schema.Schema{
 			Type: "object",
 			Properties: map[string]schema.Property{
@@ -35,7 +38,7 @@ func NewTreeTool() *Tool {
 				Context: fmt.Sprintf("Will display a tree view of the directory structure for '%s' %d levels deep", path, depth),
 			}
 		},
-		Execute: func(input map[string]any) (string, error) {
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
 			path := input["path"].(string)
 
 			// Set default depth to a large number if not specified
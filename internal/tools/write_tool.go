@@ -1,19 +1,19 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"github.com/recrsn/coder/internal/schema"
-	"github.com/sergi/go-diff/diffmatchpatch"
 	"os"
-	"path/filepath"
-	"strings"
 )
 
-// NewWriteTool creates a new tool for writing files
-func NewWriteTool() *Tool {
+// NewWriteTool creates a new tool for writing files. It only overwrites
+// files as files recorded them.
+func NewWriteTool(files *FileTracker) *Tool {
 	return &Tool{
 		Name:        "write",
-		Description: "Write content to a file",
+		Description: "Write content to a file. An existing file must be read first.",
+		PathArgs:    []string{"path"},
 		InputSchema: schema.Schema{
 			Type: "object",
 			Properties: map[string]schema.Property{
@@ -48,9 +48,8 @@ func NewWriteTool() *Tool {
 			var explainContent string
 			existingContent, err := os.ReadFile(path)
 			if err == nil {
-				diffText := generatePrettyDiff(string(existingContent), content)
-				explainContent = fmt.Sprintf("Will write %s to '%s'\n\nDiff:\n```diff\n%s\n```",
-					contentDesc, path, diffText)
+				explainContent = fmt.Sprintf("Will write %s to '%s'\n\n%s",
+					contentDesc, path, explainDiff(path, string(existingContent), content))
 			} else {
 				// If file doesn't exist or can't be read, just show the new content
 				explainContent = fmt.Sprintf("Will write %s to '%s'\n\nNew content:\n```\n%s\n```",
@@ -62,45 +61,22 @@ func NewWriteTool() *Tool {
 				Context: explainContent,
 			}
 		},
-		Execute: func(input map[string]any) (string, error) {
+		Execute: func(ctx context.Context, input map[string]any) (string, error) {
 			// Extract parameters
 			path, _ := input["path"].(string)
 			content, _ := input["content"].(string)
 
-			// Ensure the directory exists
-			dir := filepath.Dir(path)
-			if err := os.MkdirAll(dir, 0755); err != nil {
-				return "", fmt.Errorf("failed to create directory: %w", err)
+			if err := files.CheckWrite(path); err != nil {
+				return "", err
 			}
 
-			// Write the file
-			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-				return "", fmt.Errorf("failed to write file: %w", err)
+			// Write the file, creating its directory
+			if err := WriteFiles([]FileChange{{Path: path, Content: []byte(content)}}); err != nil {
+				return "", err
 			}
+			files.Record(path, []byte(content))
 
 			return fmt.Sprintf("File written to %s (%d bytes)", path, len(content)), nil
 		},
 	}
 }
-
-// generatePrettyDiff creates a colorized diff using the go-diff library
-func generatePrettyDiff(oldText, newText string) string {
-	dmp := diffmatchpatch.New()
-	diffs := dmp.DiffMain(oldText, newText, true)
-
-	// Create a string builder to hold the diff output
-	var diffOutput strings.Builder
-	for _, d := range diffs {
-		switch d.Type {
-		case diffmatchpatch.DiffEqual:
-			diffOutput.WriteString(d.Text)
-		case diffmatchpatch.DiffInsert:
-			diffOutput.WriteString(fmt.Sprintf("\x1b[32m%s\x1b[0m", d.Text)) // Green for insertions
-		case diffmatchpatch.DiffDelete:
-			diffOutput.WriteString(fmt.Sprintf("\x1b[31m%s\x1b[0m", d.Text)) // Red for deletions
-		}
-		diffOutput.WriteString("\n")
-	}
-
-	return diffOutput.String()
-}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplainDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	name := strings.TrimPrefix(filepath.ToSlash(path), "/")
+	diff := "```diff\n--- a/" + name + "\n+++ b/" + name + "\n@@ -1,5 +1,5 @@\n package main\n \n func main() {\n" +
+		"-\tprintln(\"hello\")\n+\tprintln(\"bye\")\n }\n```"
+
+	tests := []struct {
+		name  string
+		tool  *Tool
+		input map[string]any
+	}{
+		{
+			name:  "write",
+			tool:  NewWriteTool(nil),
+			input: map[string]any{"path": path, "content": "package main\n\nfunc main() {\n\tprintln(\"bye\")\n}\n"},
+		},
+		{
+			name:  "sed",
+			tool:  NewSedTool(nil),
+			input: map[string]any{"file": path, "pattern": "hello", "replacement": "bye"},
+		},
+		{
+			name:  "sed with a regular expression",
+			tool:  NewSedTool(nil),
+			input: map[string]any{"file": path, "pattern": "h[a-z]+o", "replacement": "bye", "useRegex": true},
+		},
+		{
+			name:  "search_replace",
+			tool:  NewSearchReplaceTool(nil),
+			input: map[string]any{"file": path, "search": "\"hello\"", "replacement": "\"bye\""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			context := tt.tool.Explain(tt.input).Context
+			if !strings.HasSuffix(context, diff) {
+				t.Errorf("Expected the explanation to end with the diff:\n%s\ngot:\n%s", diff, context)
+			}
+			if strings.Contains(context, "\x1b[") {
+				t.Errorf("Expected no escape sequences in the explanation, got %q", context)
+			}
+		})
+	}
+}
+
+func TestWriteToolsKeepMode(t *testing.T) {
+	tests := []struct {
+		name  string
+		tool  *Tool
+		input map[string]any
+	}{
+		{"write", NewWriteTool(nil), map[string]any{"content": "echo bye\n"}},
+		{"sed", NewSedTool(nil), map[string]any{"pattern": "hello", "replacement": "bye"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "script.sh")
+			if err := os.WriteFile(path, []byte("echo hello\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			tt.input["path"] = path
+			tt.input["file"] = path
+
+			if _, err := tt.tool.Run(context.Background(), tt.input); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			content, _ := os.ReadFile(path)
+			info, err := os.Stat(path)
+			if err != nil || string(content) != "echo bye\n" || info.Mode().Perm() != 0755 {
+				t.Errorf("Expected the new content with mode 0755, got %q, %v", content, info.Mode().Perm())
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -14,6 +15,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/pterm/pterm"
+	"github.com/recrsn/coder/internal/common"
 	"github.com/recrsn/coder/internal/config"
 )
 
@@ -49,6 +51,10 @@ var (
 			Foreground(lipgloss.Color("#32CD32")).
 			Bold(true)
 
+	modeStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFA500")).
+			Bold(true)
+
 	boxStyle = lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
 			BorderForeground(lipgloss.Color("#7D56F4")).
@@ -69,6 +75,7 @@ type Message struct {
 	Content    string
 	IsUser     bool
 	IsMarkdown bool
+	IsPartial  bool // Still being streamed from the model
 }
 
 // Tool call result
@@ -84,6 +91,8 @@ type ToolCall struct {
 type model struct {
 	messages      []Message
 	toolCalls     []ToolCall
+	liveTool      string // The tool whose output is being streamed
+	liveOutput    string // The latest output of liveTool
 	viewport      viewport.Model
 	textarea      textarea.Model
 	spinner       spinner.Model
@@ -91,6 +100,10 @@ type model struct {
 	err           error
 	inPrompt      bool // Whether we're showing a permission/confirmation prompt
 	promptText    string
+	promptGrant   string // Rule the prompt can grant for the session or always
+	mode          common.PermissionMode
+	jobs          int    // Running background jobs
+	onCycleMode   func() // Called when the user presses shift+tab
 	viewportReady bool
 	spinnerActive bool
 	activeSpinner string
@@ -105,6 +118,7 @@ type model struct {
 type promptEvent struct {
 	confirmed bool
 	text      string
+	scope     common.PermissionScope
 }
 
 // NewBubbleTeaUI creates a new Bubble Tea UI instance
@@ -215,7 +229,7 @@ func (ui *BubbleTeaUI) StopSpinner(spinner *pterm.SpinnerPrinter, text string) {
 func (ui *BubbleTeaUI) StopSpinnerFail(spinner *pterm.SpinnerPrinter, text string) {
 	ui.model.spinnerActive = false
 	ui.model.activeSpinner = ""
-	ui.model.err = fmt.Errorf(text)
+	ui.model.err = errors.New(text)
 	ui.model.spinnerDone <- text
 	ui.triggerRender()
 }
@@ -231,6 +245,14 @@ func (ui *BubbleTeaUI) PrintUserMessage(message string) {
 
 // PrintAssistantMessage prints an assistant message with markdown formatting
 func (ui *BubbleTeaUI) PrintAssistantMessage(message string) {
+	// Replace the streamed text with the final message
+	if last := ui.lastMessage(); last != nil && last.IsPartial {
+		last.Content = message
+		last.IsPartial = false
+		ui.triggerRender()
+		return
+	}
+
 	ui.model.messages = append(ui.model.messages, Message{
 		Content:    message,
 		IsUser:     false,
@@ -239,6 +261,30 @@ func (ui *BubbleTeaUI) PrintAssistantMessage(message string) {
 	ui.triggerRender()
 }
 
+// PrintAssistantDelta appends streamed text to the assistant message in progress
+func (ui *BubbleTeaUI) PrintAssistantDelta(delta string) {
+	last := ui.lastMessage()
+	if last == nil || !last.IsPartial {
+		ui.model.messages = append(ui.model.messages, Message{
+			IsUser:     false,
+			IsMarkdown: true,
+			IsPartial:  true,
+		})
+		last = ui.lastMessage()
+	}
+
+	last.Content += delta
+	ui.triggerRender()
+}
+
+// lastMessage returns the most recent message, or nil if there are none
+func (ui *BubbleTeaUI) lastMessage() *Message {
+	if len(ui.model.messages) == 0 {
+		return nil
+	}
+	return &ui.model.messages[len(ui.model.messages)-1]
+}
+
 // PrintCodeBlock prints a code block with a highlighted box
 func (ui *BubbleTeaUI) PrintCodeBlock(code, language string) {
 	content := fmt.Sprintf("```%s\n%s\n```", language, code)
@@ -263,10 +309,41 @@ func (ui *BubbleTeaUI) PrintToolCall(toolName string, args map[string]any, resul
 		toolCall.ErrText = err.Error()
 	}
 
+	ui.model.liveTool = ""
+	ui.model.liveOutput = ""
 	ui.model.toolCalls = append(ui.model.toolCalls, toolCall)
 	ui.triggerRender()
 }
 
+// liveOutputLines is how many lines of a running tool's output are shown
+const liveOutputLines = 10
+
+// PrintToolOutput shows the latest output of a running tool
+func (ui *BubbleTeaUI) PrintToolOutput(toolName string, text string) {
+	if ui.model.liveTool != toolName {
+		ui.model.liveTool = toolName
+		ui.model.liveOutput = ""
+	}
+
+	lines := strings.Split(ui.model.liveOutput+text, "\n")
+	if len(lines) > liveOutputLines {
+		lines = lines[len(lines)-liveOutputLines:]
+	}
+	ui.model.liveOutput = strings.Join(lines, "\n")
+	ui.triggerRender()
+}
+
+// PrintDiff shows a unified diff with added lines in green and removed
+// lines in red
+func (ui *BubbleTeaUI) PrintDiff(diff string) {
+	ui.model.messages = append(ui.model.messages, Message{
+		Content:    colorDiff(diff, styledDiffColors),
+		IsUser:     false,
+		IsMarkdown: false,
+	})
+	ui.triggerRender()
+}
+
 // PrintHelp prints the help message
 func (ui *BubbleTeaUI) PrintHelp() {
 	helpText := `
@@ -276,8 +353,18 @@ Commands:
 /clear    - Clear the screen
 /config   - Show or edit configuration
 /tools    - List available tools
+/resume   - Resume a saved conversation
+/compact  - Summarize older messages to free up context
+/cost     - Show token usage and cost
+/mode     - Show or change the permission mode
+/add-dir  - Let file tools access another directory
+/jobs     - List background jobs, /jobs kill <id> to kill one
+/undo     - Undo the last file change, /undo turn for those of the last turn
+/checkpoints - List the file changes /undo can restore
+/diff     - Show the changes made to files in this session
 /prompt   - Edit the prompt template
 /version  - Show version information
+Shift+Tab - Switch permission mode
 Ctrl+C    - Interrupt current operation
 Ctrl+D    - Exit the application
 `
@@ -292,7 +379,7 @@ Ctrl+D    - Exit the application
 
 // PrintError prints an error message
 func (ui *BubbleTeaUI) PrintError(message string) {
-	ui.model.err = fmt.Errorf(message)
+	ui.model.err = errors.New(message)
 	ui.triggerRender()
 }
 
@@ -365,17 +452,37 @@ func (ui *BubbleTeaUI) AskToolCallConfirmation(explanation string) (bool, string
 }
 
 // AskPermission asks the user for permission with a title and context
-func (ui *BubbleTeaUI) AskPermission(explanation string) (bool, string) {
+func (ui *BubbleTeaUI) AskPermission(explanation string, grant string) (bool, common.PermissionScope, string) {
 	ui.model.inPrompt = true
 	ui.model.promptText = "Permission Request: " + explanation
+	ui.model.promptGrant = grant
 	ui.triggerRender()
 
 	// Wait for response from prompt channel
 	resp := <-ui.model.promptCh
 	ui.model.inPrompt = false
+	ui.model.promptGrant = ""
 	ui.triggerRender()
 
-	return resp.confirmed, resp.text
+	return resp.confirmed, resp.scope, resp.text
+}
+
+// SetMode shows the permission mode in the title bar
+func (ui *BubbleTeaUI) SetMode(mode common.PermissionMode) {
+	ui.model.mode = mode
+	ui.triggerRender()
+}
+
+// SetJobs shows the number of running background jobs in the title bar
+func (ui *BubbleTeaUI) SetJobs(running int) {
+	ui.model.jobs = running
+	ui.triggerRender()
+}
+
+// OnCycleMode sets the handler of the keybinding that switches to the next
+// permission mode
+func (ui *BubbleTeaUI) OnCycleMode(handler func()) {
+	ui.model.onCycleMode = handler
 }
 
 // Helper method to trigger a re-render
@@ -434,6 +541,20 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					// Channel full, ignore
 				}
 			}
+		case "shift+tab":
+			if m.onCycleMode != nil && !m.inPrompt {
+				m.onCycleMode()
+				return m, nil
+			}
+		case "s", "a":
+			if m.inPrompt && m.promptGrant != "" {
+				scope := common.ScopeSession
+				if msg.String() == "a" {
+					scope = common.ScopeAlways
+				}
+				m.promptCh <- promptEvent{confirmed: true, scope: scope}
+				return m, nil
+			}
 		case "esc":
 			if m.inPrompt {
 				m.promptCh <- promptEvent{confirmed: false, text: ""}
@@ -517,6 +638,10 @@ func (m *model) updateViewportContent() {
 	for _, msg := range m.messages {
 		if msg.IsUser {
 			content.WriteString(userMsgStyle.Render("You: " + msg.Content))
+		} else if msg.IsPartial {
+			// Partial markdown may not render cleanly, show it as plain text
+			content.WriteString(assistantMsgStyle.Render("$ "))
+			content.WriteString(msg.Content)
 		} else if msg.IsMarkdown {
 			content.WriteString(assistantMsgStyle.Render("$ "))
 			content.WriteString(string(md.Render(msg.Content, 80, 0)))
@@ -545,6 +670,12 @@ func (m *model) updateViewportContent() {
 		}
 	}
 
+	// Add the output of a running tool
+	if m.liveTool != "" {
+		content.WriteString("\n" + boxStyle.Render("Running: "+m.liveTool) + "\n")
+		content.WriteString(m.liveOutput + "\n")
+	}
+
 	// Add spinner if active
 	if m.spinnerActive && m.activeSpinner != "" {
 		content.WriteString("\n" + spinnerStyle.Render(m.spinner.View()) + " " + m.activeSpinner + "\n")
@@ -558,8 +689,12 @@ func (m *model) updateViewportContent() {
 
 	// Add prompt if in prompt mode
 	if m.inPrompt {
-		content.WriteString("\n" + boxStyle.Render(m.promptText) + "\n")
-		content.WriteString("[Enter] Confirm   [Esc] Reject\n")
+		content.WriteString("\n" + boxStyle.Render(colorDiffBlocks(m.promptText, styledDiffColors)) + "\n")
+		if m.promptGrant != "" {
+			content.WriteString(fmt.Sprintf("[Enter] Confirm   [s] Allow %s for this session   [a] Always allow   [Esc] Reject\n", m.promptGrant))
+		} else {
+			content.WriteString("[Enter] Confirm   [Esc] Reject\n")
+		}
 	}
 
 	m.viewport.SetContent(content.String())
@@ -582,6 +717,12 @@ func (m *model) View() string {
 
 	// Start with title
 	view := titleStyle.Render("Coder - Your Programming Sidekick")
+	if m.mode != "" && m.mode != common.ModeDefault {
+		view += " " + modeStyle.Render(string(m.mode)+" mode")
+	}
+	if m.jobs > 0 {
+		view += " " + infoStyle.Render(fmt.Sprintf("jobs running: %d", m.jobs))
+	}
 	view += "\n"
 
 	// Add viewport with messages
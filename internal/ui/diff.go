@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/pterm/pterm"
+)
+
+// diffColors color the kinds of lines of a unified diff
+type diffColors struct {
+	header  func(line string) string
+	hunk    func(line string) string
+	added   func(line string) string
+	removed func(line string) string
+}
+
+// terminalDiffColors color diffs printed directly to the terminal
+var terminalDiffColors = diffColors{
+	header:  func(line string) string { return pterm.Bold.Sprint(line) },
+	hunk:    func(line string) string { return pterm.FgCyan.Sprint(line) },
+	added:   func(line string) string { return pterm.FgGreen.Sprint(line) },
+	removed: func(line string) string { return pterm.FgRed.Sprint(line) },
+}
+
+// styledDiffColors color diffs rendered in the BubbleTea UI
+var styledDiffColors = diffColors{
+	header:  renderWith(lipgloss.NewStyle().Bold(true)),
+	hunk:    renderWith(lipgloss.NewStyle().Foreground(lipgloss.Color("#00AFAF"))),
+	added:   renderWith(lipgloss.NewStyle().Foreground(lipgloss.Color("#32CD32"))),
+	removed: renderWith(lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F5F"))),
+}
+
+func renderWith(style lipgloss.Style) func(line string) string {
+	return func(line string) string { return style.Render(line) }
+}
+
+// colorDiff colors the lines of a unified diff
+func colorDiff(diff string, colors diffColors) string {
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+			lines[i] = colors.header(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = colors.hunk(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = colors.added(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = colors.removed(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// colorDiffBlocks colors the ```diff code blocks of text and removes their
+// fences, leaving the rest of text as it is
+func colorDiffBlocks(text string, colors diffColors) string {
+	var out strings.Builder
+	for {
+		start := strings.Index(text, "```diff\n")
+		if start < 0 {
+			break
+		}
+		body := text[start+len("```diff\n"):]
+		end := strings.Index(body, "\n```")
+		if end < 0 {
+			break
+		}
+		out.WriteString(text[:start])
+		out.WriteString(colorDiff(body[:end], colors))
+		text = body[end+len("\n```"):]
+	}
+	out.WriteString(text)
+	return out.String()
+}
@@ -0,0 +1,103 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pterm/pterm"
+	"github.com/recrsn/coder/internal/common"
+)
+
+// HeadlessUI is the UI of a non-interactive run. Assistant messages and tool
+// calls are passed to callbacks that produce the run's output, diagnostics
+// go to a separate writer, and every question is answered negatively.
+type HeadlessUI struct {
+	onMessage  func(message string)
+	onToolCall func(toolName string, args map[string]any, result string, err error)
+	diagnostic io.Writer
+}
+
+// NewHeadlessUI creates a headless UI. Errors and information are written to
+// diagnostic, usually stderr.
+func NewHeadlessUI(
+	onMessage func(message string),
+	onToolCall func(toolName string, args map[string]any, result string, err error),
+	diagnostic io.Writer,
+) *HeadlessUI {
+	return &HeadlessUI{
+		onMessage:  onMessage,
+		onToolCall: onToolCall,
+		diagnostic: diagnostic,
+	}
+}
+
+func (u *HeadlessUI) ShowHeader() {}
+
+func (u *HeadlessUI) StartSpinner(text string) *pterm.SpinnerPrinter {
+	return nil
+}
+
+func (u *HeadlessUI) StopSpinner(spinner *pterm.SpinnerPrinter, text string) {}
+
+func (u *HeadlessUI) StopSpinnerFail(spinner *pterm.SpinnerPrinter, text string) {
+	u.PrintError(text)
+}
+
+func (u *HeadlessUI) PrintUserMessage(message string) {}
+
+func (u *HeadlessUI) PrintAssistantMessage(message string) {
+	if u.onMessage != nil {
+		u.onMessage(message)
+	}
+}
+
+// PrintAssistantDelta ignores streamed text, the complete message follows
+func (u *HeadlessUI) PrintAssistantDelta(delta string) {}
+
+func (u *HeadlessUI) PrintCodeBlock(code, language string) {}
+
+func (u *HeadlessUI) PrintToolCall(toolName string, args map[string]any, result string, err error) {
+	if u.onToolCall != nil {
+		u.onToolCall(toolName, args, result, err)
+	}
+}
+
+func (u *HeadlessUI) PrintHelp() {}
+
+func (u *HeadlessUI) PrintError(message string) {
+	_, _ = fmt.Fprintln(u.diagnostic, "Error: "+message)
+}
+
+func (u *HeadlessUI) PrintSuccess(message string) {}
+
+func (u *HeadlessUI) PrintInfo(message string) {
+	_, _ = fmt.Fprintln(u.diagnostic, message)
+}
+
+func (u *HeadlessUI) AskInput(prompt string) string {
+	return ""
+}
+
+func (u *HeadlessUI) AskMultiLineInput(prompt string) string {
+	return ""
+}
+
+func (u *HeadlessUI) ClearScreen() {}
+
+func (u *HeadlessUI) AskToolCallConfirmation(explanation string) (bool, string) {
+	return false, "No user is available to confirm this in non-interactive mode"
+}
+
+func (u *HeadlessUI) SetMode(mode common.PermissionMode) {}
+
+func (u *HeadlessUI) PrintToolOutput(toolName string, text string) {}
+
+func (u *HeadlessUI) PrintDiff(diff string) {
+	_, _ = fmt.Fprintln(u.diagnostic, diff)
+}
+
+func (u *HeadlessUI) SetJobs(running int) {}
+
+func (u *HeadlessUI) AskPermission(explanation string, grant string) (bool, common.PermissionScope, string) {
+	return false, common.ScopeOnce, "No user is available to grant permission in non-interactive mode"
+}
@@ -27,6 +27,14 @@ func (h *UIPermissionHandler) RequestPermission(request common.PermissionRequest
 	explanation += fmt.Sprintf("%s\n\n", request.Title)
 	explanation += request.Context + "\n\n"
 
+	if len(request.Outside) > 0 {
+		explanation += "Outside the workspace:\n"
+		for _, path := range request.Outside {
+			explanation += fmt.Sprintf("  %s\n", path)
+		}
+		explanation += "\n"
+	}
+
 	// Arguments
 	explanation += "Arguments:\n"
 	for k, v := range request.Arguments {
@@ -34,10 +42,11 @@ func (h *UIPermissionHandler) RequestPermission(request common.PermissionRequest
 	}
 
 	// Ask for user confirmation
-	granted, alternate := h.ui.AskPermission(explanation)
+	granted, scope, alternate := h.ui.AskPermission(explanation, request.GrantRule())
 
 	return common.PermissionResponse{
 		Granted:         granted,
 		AlternateAction: alternate,
+		Scope:           scope,
 	}
 }
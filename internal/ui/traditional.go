@@ -5,6 +5,7 @@ import (
 	"io"
 	"strings"
 
+	"github.com/recrsn/coder/internal/common"
 	"github.com/recrsn/coder/internal/config"
 
 	md "github.com/MichaelMure/go-term-markdown"
@@ -17,6 +18,10 @@ type TraditionalUI struct {
 	config      config.UIConfig
 	readline    *readline.Instance
 	exitHandler func()
+	streaming   bool   // Whether an assistant message is being streamed
+	outputTool  string // The tool whose output is being streamed
+	mode        common.PermissionMode
+	jobs        int // Running background jobs
 }
 
 // NewTraditionalUI creates a new TraditionalUI instance
@@ -111,10 +116,26 @@ func parseMarkdown(text string) string {
 
 // PrintAssistantMessage prints an assistant message with markdown formatting
 func (u *TraditionalUI) PrintAssistantMessage(message string) {
+	// The text has already been printed as it streamed in
+	if u.streaming {
+		u.streaming = false
+		fmt.Println()
+		return
+	}
+
 	fmt.Print("$ ")
 	fmt.Println(parseMarkdown(message))
 }
 
+// PrintAssistantDelta prints streamed assistant text as it arrives
+func (u *TraditionalUI) PrintAssistantDelta(delta string) {
+	if !u.streaming {
+		u.streaming = true
+		fmt.Print("$ ")
+	}
+	fmt.Print(delta)
+}
+
 // PrintCodeBlock prints a code block with a highlighted box
 func (u *TraditionalUI) PrintCodeBlock(code, language string) {
 	fmt.Println()
@@ -124,6 +145,11 @@ func (u *TraditionalUI) PrintCodeBlock(code, language string) {
 
 // PrintToolCall prints information about a tool call
 func (u *TraditionalUI) PrintToolCall(toolName string, args map[string]any, result string, err error) {
+	if u.outputTool != "" {
+		u.outputTool = ""
+		fmt.Println()
+	}
+
 	panel := pterm.DefaultBox.WithTitle("Tool: " + toolName)
 
 	var content strings.Builder
@@ -145,6 +171,21 @@ func (u *TraditionalUI) PrintToolCall(toolName string, args map[string]any, resu
 	panel.Println(content.String())
 }
 
+// PrintToolOutput prints the output of a running tool as it arrives
+func (u *TraditionalUI) PrintToolOutput(toolName string, text string) {
+	if u.outputTool != toolName {
+		u.outputTool = toolName
+		fmt.Println(pterm.Gray("Output of " + toolName + ":"))
+	}
+	fmt.Print(text)
+}
+
+// PrintDiff prints a unified diff with added lines in green and removed
+// lines in red
+func (u *TraditionalUI) PrintDiff(diff string) {
+	fmt.Println(colorDiff(diff, terminalDiffColors))
+}
+
 // PrintHelp prints the help message
 func (u *TraditionalUI) PrintHelp() {
 	table := pterm.TableData{
@@ -154,6 +195,15 @@ func (u *TraditionalUI) PrintHelp() {
 		{"/clear", "Clear the screen"},
 		{"/config", "Show or edit configuration"},
 		{"/tools", "List available tools"},
+		{"/resume", "Resume a saved conversation"},
+		{"/compact", "Summarize older messages to free up context"},
+		{"/cost", "Show token usage and cost"},
+		{"/mode", "Show or change the permission mode"},
+		{"/add-dir", "Let file tools access another directory"},
+		{"/jobs", "List background jobs, /jobs kill <id> to kill one"},
+		{"/undo", "Undo the last file change, /undo turn for those of the last turn"},
+		{"/checkpoints", "List the file changes /undo can restore"},
+		{"/diff", "Show the changes made to files in this session"},
 		{"/prompt", "Edit the prompt template"},
 		{"/version", "Show version information"},
 		{"Ctrl+C", "Interrupt current operation"},
@@ -181,8 +231,24 @@ func (u *TraditionalUI) PrintInfo(message string) {
 	pterm.Info.Println(message)
 }
 
+// SetMode shows the permission mode in front of the input prompt
+func (u *TraditionalUI) SetMode(mode common.PermissionMode) {
+	u.mode = mode
+}
+
+// SetJobs shows the number of running background jobs in front of the input prompt
+func (u *TraditionalUI) SetJobs(running int) {
+	u.jobs = running
+}
+
 // AskInput asks for user input with a prompt
 func (u *TraditionalUI) AskInput(prompt string) string {
+	if u.mode != "" && u.mode != common.ModeDefault {
+		prompt = fmt.Sprintf("[%s] %s", u.mode, prompt)
+	}
+	if u.jobs > 0 {
+		prompt = fmt.Sprintf("[jobs: %d] %s", u.jobs, prompt)
+	}
 	u.readline.SetPrompt(prompt)
 	defer u.readline.SetPrompt("> ")
 
@@ -222,7 +288,7 @@ func (u *TraditionalUI) ClearScreen() {
 
 func (u *TraditionalUI) AskToolCallConfirmation(explanation string) (bool, string) {
 	pterm.DefaultBox.WithTitle("Confirm tool call").
-		Println(explanation)
+		Println(colorDiffBlocks(explanation, terminalDiffColors))
 
 	confirmation, _ := pterm.DefaultInteractiveConfirm.
 		WithRejectText("No, and tell what to do instead").
@@ -237,18 +303,36 @@ func (u *TraditionalUI) AskToolCallConfirmation(explanation string) (bool, strin
 }
 
 // AskPermission asks the user for permission with a title and context
-func (u *TraditionalUI) AskPermission(explanation string) (bool, string) {
+func (u *TraditionalUI) AskPermission(explanation string, grant string) (bool, common.PermissionScope, string) {
 	pterm.DefaultBox.WithTitle("Permission Request").
-		Println(explanation)
+		Println(colorDiffBlocks(explanation, terminalDiffColors))
+
+	const (
+		allowOnce = "Yes, allow this action"
+		deny      = "No, deny this action"
+	)
+	allowSession := fmt.Sprintf("Yes, and allow %s for this session", grant)
+	allowAlways := fmt.Sprintf("Yes, and always allow %s", grant)
+
+	options := []string{allowOnce}
+	if grant != "" {
+		options = append(options, allowSession, allowAlways)
+	}
+	options = append(options, deny)
 
-	confirmation, _ := pterm.DefaultInteractiveConfirm.
-		WithConfirmText("Yes, allow this action").
-		WithRejectText("No, deny this action").
+	choice, _ := pterm.DefaultInteractiveSelect.
+		WithOptions(options).
+		WithDefaultOption(allowOnce).
 		Show()
 
-	if confirmation {
-		return true, ""
+	switch choice {
+	case allowOnce:
+		return true, common.ScopeOnce, ""
+	case allowSession:
+		return true, common.ScopeSession, ""
+	case allowAlways:
+		return true, common.ScopeAlways, ""
 	}
 
-	return false, u.AskInput("What should I do instead?")
+	return false, common.ScopeOnce, u.AskInput("What should I do instead?")
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/pterm/pterm"
+	"github.com/recrsn/coder/internal/common"
 	"github.com/recrsn/coder/internal/config"
 )
 
@@ -16,8 +17,13 @@ type UserInterface interface {
 	StopSpinnerFail(spinner *pterm.SpinnerPrinter, text string)
 	PrintUserMessage(message string)
 	PrintAssistantMessage(message string)
+	PrintAssistantDelta(delta string)
 	PrintCodeBlock(code, language string)
 	PrintToolCall(toolName string, args map[string]any, result string, err error)
+	// PrintToolOutput shows output of a tool while it runs, before PrintToolCall
+	PrintToolOutput(toolName string, text string)
+	// PrintDiff shows a unified diff
+	PrintDiff(diff string)
 	PrintHelp()
 	PrintError(message string)
 	PrintSuccess(message string)
@@ -26,7 +32,13 @@ type UserInterface interface {
 	AskMultiLineInput(prompt string) string
 	ClearScreen()
 	AskToolCallConfirmation(explanation string) (bool, string)
-	AskPermission(explanation string) (bool, string)
+	// AskPermission asks to grant a tool call. Unless grant is empty the user
+	// may also grant the calls matching that rule for the session or always.
+	AskPermission(explanation string, grant string) (bool, common.PermissionScope, string)
+	// SetMode shows the session's permission mode
+	SetMode(mode common.PermissionMode)
+	// SetJobs shows how many background jobs are running
+	SetJobs(running int)
 }
 
 // NewUI creates a new UI instance based on config
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"github.com/recrsn/coder/internal/chat"
 	"github.com/recrsn/coder/internal/chat/prompts"
@@ -12,12 +14,28 @@ import (
 	"github.com/recrsn/coder/internal/tools"
 	lsptools "github.com/recrsn/coder/internal/tools/lsp"
 	"github.com/recrsn/coder/internal/ui"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 )
 
 func main() {
+	// The shell sandbox runs this binary to set itself up
+	tools.HandleSandboxHelper()
+
+	continueLatest := flag.Bool("continue", false, "Continue the most recent session in this directory")
+	prompt := flag.String("p", "", "Run this prompt non-interactively and exit, \"-\" to read it from stdin")
+	outputFormat := flag.String("output-format", chat.OutputText, "Output of a non-interactive run: text, json or stream-json")
+	maxTurns := flag.Int("max-turns", 0, "Maximum number of model requests of a non-interactive run, 0 for no limit")
+	allowedTools := flag.String("allowed-tools", "", "Comma separated tools a non-interactive run may use without asking")
+	flag.Parse()
+
+	headless := isFlagSet("p")
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		fmt.Printf("Error loading config: %v\n", err)
@@ -26,15 +44,18 @@ func main() {
 	}
 
 	registry := tools.NewRegistry()
+	registry.SetTimeouts(cfg.Tools.Timeouts)
 
-	registry.Register("shell", tools.NewShellTool())
 	registry.Register("ls", tools.NewLSTool())
 	registry.Register("glob", tools.NewGlobTool())
-	registry.Register("sed", tools.NewSedTool())
+	// File tools refuse to overwrite changes made since the session read a file
+	files := tools.NewFileTracker()
+	registry.Register("sed", tools.NewSedTool(files))
 	registry.Register("grep", tools.NewGrepTool())
-	registry.Register("write", tools.NewWriteTool())
-	registry.Register("read", tools.NewReadTool())
-	registry.Register("search_replace", tools.NewSearchReplaceTool())
+	registry.Register("write", tools.NewWriteTool(files))
+	registry.Register("read", tools.NewReadTool(files))
+	registry.Register("search_replace", tools.NewSearchReplaceTool(files))
+	registry.Register("apply_patch", tools.NewApplyPatchTool(files))
 	registry.Register("tree", tools.NewTreeTool())
 	registry.Register("outline", tools.NewOutlineTool())
 
@@ -46,30 +67,61 @@ func main() {
 		registry.Register("lsp_references", lsptools.NewReferencesTool(lspManager))
 		registry.Register("lsp_callhierarchy", lsptools.NewCallHierarchyTool(lspManager))
 	} else {
-		fmt.Printf("Error initializing LSP manager: %v\n", err)
-		fmt.Println("LSP features may not work properly")
+		fmt.Fprintf(os.Stderr, "Error initializing LSP manager: %v\n", err)
+		fmt.Fprintln(os.Stderr, "LSP features may not work properly")
 	}
 
 	var session *chat.Session
 
-	// Create UI with exit handler
-	userInterface, err := ui.NewUI(cfg.UI, func() {
-		if session != nil {
-			session.Exit()
-		} else {
-			os.Exit(0)
+	var userInterface ui.UserInterface
+	var permissionHandler common.PermissionHandler
+	var output *chat.HeadlessOutput
+
+	if headless {
+		// Nobody is there to answer, so only pre-approved tools may run
+		output, err = chat.NewHeadlessOutput(*outputFormat, os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		userInterface = ui.NewHeadlessUI(output.HandleMessage, output.HandleToolCall, os.Stderr)
+		permissionHandler = common.NewNonInteractivePermissionHandler(splitList(*allowedTools))
+	} else {
+		// Create UI with exit handler
+		userInterface, err = ui.NewUI(cfg.UI, func() {
+			if session != nil {
+				session.Exit()
+			} else {
+				os.Exit(0)
+			}
+		})
+		if err != nil {
+			fmt.Printf("Error creating UI: %v\n", err)
+			os.Exit(1)
 		}
-	})
-	if err != nil {
-		fmt.Printf("Error creating UI: %v\n", err)
-		os.Exit(1)
-	}
 
-	// Create the UI permission handler
-	uiPermissionHandler := ui.NewUIPermissionHandler(userInterface)
+		// Create the UI permission handler
+		permissionHandler = ui.NewUIPermissionHandler(userInterface)
+	}
 
 	// Create the permission manager
-	permissionManager := common.NewPermissionManager(cfg.Permissions, uiPermissionHandler)
+	permissionManager := common.NewPermissionManager(cfg.Permissions, permissionHandler)
+	// The shell tool needs the workspace, which the permission manager tracks
+	shell := tools.NewShell(tools.ShellOptions{
+		Sandbox:   newSandbox(cfg.Tools.Sandbox, permissionManager.Workspace()),
+		OutputDir: newOutputDir(permissionManager.Workspace()),
+	})
+	defer shell.Close()
+	registry.Register("shell", tools.NewShellTool(shell))
+	registry.Register("job_output", tools.NewJobOutputTool(shell))
+	registry.Register("jobs", tools.NewJobsTool(shell))
+	registry.Register("job_kill", tools.NewJobKillTool(shell))
+
+	permissionManager.SetSaveRule(func(rule config.PermissionRule) {
+		if err := config.SavePermissionRule(rule); err != nil {
+			userInterface.PrintError(fmt.Sprintf("Failed to save permission rule: %v", err))
+		}
+	})
 
 	userConfigDir, err := os.UserConfigDir()
 	if err != nil {
@@ -83,7 +135,24 @@ func main() {
 
 	apiLogger := llm.NewAPILogger(configDir)
 
-	client := llm.NewClient(cfg.Provider.Endpoint, cfg.Provider.APIKey, apiLogger)
+	client, err := llm.NewProvider(llm.ProviderOptions{
+		Type:        cfg.Provider.Type,
+		BaseURL:     cfg.Provider.Endpoint,
+		APIKey:      cfg.Provider.APIKey,
+		ToolCalling: cfg.Provider.ToolCalling,
+		Retry: llm.RetryConfig{
+			MaxRetries: cfg.Provider.MaxRetries,
+			OnRetry: func(attempt int, delay time.Duration, err error) {
+				if session != nil {
+					session.HandleRetry(attempt, cfg.Provider.MaxRetries, delay, err)
+				}
+			},
+		},
+	}, apiLogger)
+	if err != nil {
+		fmt.Printf("Error creating provider: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Get the working directory for the prompt
 	workingDir, err := os.Getwd()
@@ -133,14 +202,139 @@ func main() {
 		session.HandleMessage,   // Use the session's message handler
 	)
 
+	if cfg.Provider.Stream && !headless {
+		agent.SetStreamCallback(session.HandleStreamDelta)
+	}
+	if headless {
+		agent.SetMaxTurns(*maxTurns)
+	}
+
 	// Set the agent in the session
 	session.SetAgent(agent)
+	session.SetShell(shell)
+
+	if bubbleTea, ok := userInterface.(*ui.BubbleTeaUI); ok {
+		bubbleTea.OnCycleMode(session.CycleMode)
+	}
 
 	// Register agent tool with the same client
-	registry.Register("agent", tools.NewAgentTool(registry, client, userInterface, cfg.Provider.Model, permissionManager))
+	registry.Register("agent", tools.NewAgentTool(registry, client, userInterface, cfg.Provider.Model, permissionManager, session.UsageTracker(), cfg.Tools.ParallelWorkers))
 
-	if err := session.Start(); err != nil {
+	// os.Exit skips the deferred calls, so background jobs and language
+	// servers are stopped before it
+	exit := func(code int) {
+		shell.Close()
+		if lspManager != nil {
+			lspManager.StopAllServers()
+		}
+		os.Exit(code)
+	}
+
+	if headless {
+		exit(runHeadless(session, output, *prompt))
+	}
+
+	if err := session.Start(*continueLatest); err != nil {
 		fmt.Printf("Error in chat session: %v\n", err)
-		os.Exit(1)
+		exit(1)
+	}
+}
+
+// newSandbox returns the sandbox of shell commands, nil if it is disabled
+func newSandbox(cfg config.SandboxConfig, workspace *common.Workspace) *tools.Sandbox {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &tools.Sandbox{
+		WorkspaceDirs: workspace.Roots,
+		WritableDirs:  cfg.WritableDirs,
+		Network:       cfg.Network,
+		CPUSeconds:    cfg.CPUSeconds,
+		MemoryMB:      cfg.MemoryMB,
+		MaxProcesses:  cfg.MaxProcesses,
+	}
+}
+
+// newOutputDir creates the directory for the full output of commands and adds
+// it to the workspace so that it can be read. It returns an empty string if
+// the directory can't be created.
+func newOutputDir(workspace *common.Workspace) string {
+	dir, err := os.MkdirTemp("", "coder-output-")
+	if err == nil {
+		dir, err = workspace.AddDir(dir)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: the full output of commands won't be kept: %v\n", err)
+		return ""
+	}
+	return dir
+}
+
+// runHeadless runs prompt to completion and writes the result to stdout. It
+// returns the process exit code.
+func runHeadless(session *chat.Session, output *chat.HeadlessOutput, prompt string) int {
+	prompt, err := readPrompt(prompt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	message, runErr := session.RunPrompt(ctx, prompt)
+	if err := output.Finish(message, session.UsageTracker().Summary(), runErr); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if runErr != nil {
+		return 1
+	}
+	return 0
+}
+
+// readPrompt returns the prompt of a non-interactive run. Input piped to
+// stdin is the prompt when it is "-" and is appended to it otherwise.
+func readPrompt(prompt string) (string, error) {
+	stat, err := os.Stdin.Stat()
+	piped := err == nil && stat.Mode()&os.ModeCharDevice == 0
+
+	if prompt == "-" || piped {
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("reading prompt from stdin: %w", err)
+		}
+		if prompt == "-" {
+			prompt = string(input)
+		} else if len(input) > 0 {
+			prompt += "\n\n" + string(input)
+		}
+	}
+
+	if strings.TrimSpace(prompt) == "" {
+		return "", fmt.Errorf("empty prompt")
+	}
+	return prompt, nil
+}
+
+// isFlagSet reports whether the named flag was given on the command line
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// splitList splits a comma separated list, ignoring empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }